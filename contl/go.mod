@@ -1,3 +1,3 @@
-module contl
+module github.com/nchern/cli-tools/contl
 
 go 1.15
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// maxPendingLines and maxPendingSize limit how much of a JSON value
+	// is buffered before it is given up on and printed as is
+	maxPendingLines = 10000
+	maxPendingSize  = 1024 * 1024
+)
+
+// jsonDepth tracks nesting of a JSON text fed to it line by line
+type jsonDepth struct {
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+func (d *jsonDepth) feed(s string) {
+	for _, c := range s {
+		if d.inString {
+			switch {
+			case d.escaped:
+				d.escaped = false
+			case c == '\\':
+				d.escaped = true
+			case c == '"':
+				d.inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			d.inString = true
+		case '{', '[':
+			d.depth++
+		case '}', ']':
+			d.depth--
+		}
+	}
+}
+
+func startsJSON(line string) bool {
+	s := strings.TrimSpace(line)
+	return strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[")
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// isJSONPrefix reports whether the line may start a JSON value:
+// "[main] started {" starts with a bracket but is not a JSON
+func isJSONPrefix(line string) bool {
+	dec := json.NewDecoder(strings.NewReader(line))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// continuesValue reports whether the line may belong to the value started by first:
+// pretty printers indent everything but the closing bracket deeper than the opening line
+func continuesValue(first string, line string) bool {
+	if indentOf(line) > indentOf(first) {
+		return true
+	}
+	s := strings.TrimSpace(line)
+	return strings.HasPrefix(s, "}") || strings.HasPrefix(s, "]")
+}
+
+// readLine returns the next line without its line ending, lines are not limited in length
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if strings.HasSuffix(line, "\n") {
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	}
+	return line, err
+}
+
+// mergeJSON copies lines from r to w, turning pretty-printed multi-line JSON
+// values into compact single lines. Lines that are not JSON are passed as is.
+func mergeJSON(r io.Reader, w io.Writer) error {
+	var (
+		pending     []string
+		pendingSize int
+		d           jsonDepth
+	)
+	reset := func() { pending = pending[:0]; pendingSize = 0; d = jsonDepth{} }
+	// giveUp prints the lines back untouched: they are not a JSON after all
+	giveUp := func() error {
+		defer reset()
+		for _, l := range pending {
+			if _, err := fmt.Fprintln(w, l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	flush := func() error {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(strings.Join(pending, "\n"))); err != nil {
+			return giveUp()
+		}
+		defer reset()
+		_, err := fmt.Fprintln(w, buf.String())
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := readLine(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the partial line read before the error is printed as is too
+			if line != "" {
+				pending = append(pending, line)
+			}
+			if ferr := giveUp(); ferr != nil {
+				return ferr
+			}
+			return err
+		}
+		if len(pending) > 0 && !continuesValue(pending[0], line) {
+			// the previous value was truncated or was not a JSON at all
+			if err := giveUp(); err != nil {
+				return err
+			}
+		}
+		if len(pending) == 0 && (!startsJSON(line) || !isJSONPrefix(line)) {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			continue
+		}
+		pending = append(pending, line)
+		pendingSize += len(line)
+		d.feed(line)
+		switch {
+		case d.depth <= 0:
+			if err := flush(); err != nil {
+				return err
+			}
+		case d.inString, len(pending) >= maxPendingLines, pendingSize >= maxPendingSize:
+			// JSON strings can not span lines, so an open one means a broken value
+			if err := giveUp(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(pending) > 0 {
+		return flush()
+	}
+	return nil
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net/textproto"
 	"os"
+
+	"github.com/nchern/cli-tools/contl/unfold"
+)
+
+var (
+	jsonMode = flag.Bool("json", false, "merge pretty-printed multi-line JSON values into single compact lines")
+
+	delim = flag.String("d", unfold.DefaultDelimiter,
+		"delimiter joining continuation lines; "+unfold.IndentPlaceholder+" in it is replaced with the stripped indentation")
 )
 
 func init() {
@@ -16,6 +22,8 @@ func init() {
 		fmt.Println("The utility reads possibly continued lines from stdin, turns each continued")
 		fmt.Println("line into a single one that does not break.")
 		fmt.Println()
+		fmt.Println("With -json, pretty-printed JSON values are merged into compact lines instead.")
+		fmt.Println()
 
 		usage()
 	}
@@ -23,17 +31,14 @@ func init() {
 }
 
 func main() {
-	var err error
-	r := textproto.NewReader(bufio.NewReader(os.Stdin))
-	for {
-		var s string
-		s, err = r.ReadContinuedLine()
-		if err != nil {
-			break
+	if *jsonMode {
+		if err := mergeJSON(os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("fatal: %s", err)
 		}
-		fmt.Println(s)
+		return
 	}
-	if err != nil && err != io.EOF {
+
+	if err := unfoldLines(os.Stdin, os.Stdout, *delim); err != nil {
 		log.Fatalf("fatal: %s", err)
 	}
 }
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"io"
+
+	"github.com/nchern/cli-tools/contl/unfold"
+)
+
+// unfoldLines copies lines from r to w turning each continued line into a single one:
+// lines starting with a space or tab are appended to the previous one using delim
+func unfoldLines(r io.Reader, w io.Writer, delim string) error {
+	_, err := io.Copy(w, unfold.NewReaderOptions(r, unfold.Options{Delimiter: delim}))
+	return err
+}
@@ -0,0 +1,139 @@
+// Package unfold joins continued lines into single ones while streaming.
+// A continued line is followed by continuation lines, by default those
+// starting with a space or tab, as in RFC 5322 headers or YAML-ish logs.
+package unfold
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+const (
+	// DefaultDelimiter joins continuation lines unless another one is set
+	DefaultDelimiter = " "
+
+	// IndentPlaceholder in a delimiter is replaced with the whitespace
+	// stripped from the beginning of a continuation line
+	IndentPlaceholder = "{{indent}}"
+)
+
+const blanks = " \t"
+
+// IsIndented reports whether a line starts with a space or tab,
+// it is the default continuation rule
+func IsIndented(line string) bool {
+	return line != "" && strings.ContainsRune(blanks, rune(line[0]))
+}
+
+// Options configure how lines are unfolded
+type Options struct {
+	// Delimiter joins a continuation line to the line it continues,
+	// IndentPlaceholder in it is replaced with the stripped indentation.
+	// An empty delimiter glues the lines together.
+	Delimiter string
+
+	// IsContinuation reports whether a line continues the previous one
+	IsContinuation func(line string) bool
+}
+
+// Reader reads unfolded lines from an underlying reader
+type Reader struct {
+	opts Options
+	r    *bufio.Reader
+
+	cur     strings.Builder
+	started bool
+	out     bytes.Buffer
+	err     error
+}
+
+// NewReader returns a reader that unfolds lines read from r using
+// DefaultDelimiter and IsIndented
+func NewReader(r io.Reader) *Reader {
+	return NewReaderOptions(r, Options{Delimiter: DefaultDelimiter})
+}
+
+// NewReaderOptions returns a reader that unfolds lines read from r using opts,
+// a nil IsContinuation defaults to IsIndented
+func NewReaderOptions(r io.Reader, opts Options) *Reader {
+	if opts.IsContinuation == nil {
+		opts.IsContinuation = IsIndented
+	}
+	return &Reader{opts: opts, r: bufio.NewReader(r)}
+}
+
+func (u *Reader) expand(indent string) string {
+	return strings.Replace(u.opts.Delimiter, IndentPlaceholder, indent, -1)
+}
+
+func (u *Reader) flush() {
+	if !u.started {
+		return
+	}
+	u.out.WriteString(u.cur.String())
+	u.out.WriteByte('\n')
+	u.cur.Reset()
+}
+
+// readLine returns the next line without its line ending, lines are not limited in length.
+// A partial line read before an error is returned along with the error.
+func (u *Reader) readLine() (string, error) {
+	line, err := u.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if strings.HasSuffix(line, "\n") {
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	}
+	return line, err
+}
+
+// add appends a line to the current one or starts a new one.
+// A blank line ends the current line and is never continued, as in textproto.
+func (u *Reader) add(line string) {
+	if line == "" {
+		u.flush()
+		u.started = false
+		u.out.WriteByte('\n')
+		return
+	}
+	trimmed := strings.Trim(line, blanks)
+	if u.started && u.opts.IsContinuation(line) {
+		indent := line[:len(line)-len(strings.TrimLeft(line, blanks))]
+		u.cur.WriteString(u.expand(indent))
+		u.cur.WriteString(trimmed)
+		return
+	}
+	u.flush()
+	u.cur.WriteString(trimmed)
+	u.started = true
+}
+
+// fill reads input until at least one unfolded line is complete or the input ends
+func (u *Reader) fill() {
+	for u.out.Len() == 0 {
+		line, err := u.readLine()
+		if err == nil || line != "" {
+			u.add(line)
+		}
+		if err != nil {
+			u.flush()
+			u.started = false
+			u.err = err
+			return
+		}
+	}
+}
+
+// Read implements io.Reader, each unfolded line ends with a newline
+func (u *Reader) Read(p []byte) (int, error) {
+	if u.out.Len() == 0 && u.err == nil {
+		u.fill()
+	}
+	if u.out.Len() > 0 {
+		return u.out.Read(p)
+	}
+	return 0, u.err
+}
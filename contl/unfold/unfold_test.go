@@ -0,0 +1,84 @@
+package unfold
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func unfoldString(s string, opts Options) (string, error) {
+	out, err := ioutil.ReadAll(NewReaderOptions(strings.NewReader(s), opts))
+	return string(out), err
+}
+
+func TestReader(t *testing.T) {
+	dashed := func(line string) bool { return strings.HasPrefix(line, "-") }
+
+	var tests = []struct {
+		name     string
+		given    string
+		opts     Options
+		expected string
+	}{
+		{"no continuations", "a\nb\n", Options{Delimiter: DefaultDelimiter}, "a\nb\n"},
+		{"continuations", "a\n  b\n\tc\nd\n", Options{Delimiter: DefaultDelimiter}, "a b c\nd\n"},
+		{"no trailing new line", "a\n b", Options{Delimiter: DefaultDelimiter}, "a b\n"},
+		{"blank line ends a line and is kept", "a\n  b\n\n  c\n", Options{Delimiter: DefaultDelimiter}, "a b\n\nc\n"},
+		{"blank lines only", "\n\n", Options{Delimiter: DefaultDelimiter}, "\n\n"},
+		{"crlf", "a\r\n b\r\n\r\nc\r\n", Options{Delimiter: DefaultDelimiter}, "a b\n\nc\n"},
+		{"leading continuation", " a\n b\n", Options{Delimiter: DefaultDelimiter}, "a b\n"},
+		{"empty delimiter", "a\n b\n", Options{}, "ab\n"},
+		{"custom delimiter", "a\n b\n", Options{Delimiter: " | "}, "a | b\n"},
+		{"indent expansion", "a\n  b\n\tc\n", Options{Delimiter: "\\n" + IndentPlaceholder}, "a\\n  b\\n\tc\n"},
+		{"custom continuation", "a\n-b\n c\n", Options{Delimiter: " ", IsContinuation: dashed}, "a -b\nc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := unfoldString(tt.given, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestReaderLongLines(t *testing.T) {
+	long := strings.Repeat("x", 256*1024)
+	actual, err := ioutil.ReadAll(NewReader(strings.NewReader(long + "\n " + long + "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := long + " " + long + "\n"; string(actual) != expected {
+		t.Errorf("expected %d bytes, got %d", len(expected), len(actual))
+	}
+}
+
+// failingReader returns data and then an error other than io.EOF
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestReaderKeepsPartialLineOnError(t *testing.T) {
+	broken := errors.New("broken pipe")
+	actual, err := ioutil.ReadAll(NewReader(&failingReader{data: "a\n b\nc", err: broken}))
+	if err != broken {
+		t.Errorf("expected %v, got %v", broken, err)
+	}
+	if expected := "a b\nc\n"; string(actual) != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// fieldRange selects consecutive whitespace separated fields holding a timestamp.
+// Indexes start with 1, negative ones count from the end of the line: -1 is the last field.
+type fieldRange struct {
+	from int
+	to   int
+}
+
+func parseFieldIndex(s string) (int, error) {
+	idx, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || idx == 0 {
+		return 0, fmt.Errorf("%s: field index should be a non-zero integer", s)
+	}
+	return idx, nil
+}
+
+// parseFieldRange parses "a..b" ranges as well as single indexes
+func parseFieldRange(s string) (fieldRange, error) {
+	bounds := strings.SplitN(s, "..", 2)
+	from, err := parseFieldIndex(bounds[0])
+	if err != nil {
+		return fieldRange{}, err
+	}
+	to := from
+	if len(bounds) == 2 {
+		if to, err = parseFieldIndex(bounds[1]); err != nil {
+			return fieldRange{}, err
+		}
+	}
+	if from > 0 && to > 0 && from > to || from < 0 && to < 0 && from > to {
+		return fieldRange{}, fmt.Errorf("%s: range start is after its end", s)
+	}
+	return fieldRange{from: from, to: to}, nil
+}
+
+func (r fieldRange) String() string {
+	if r.from == r.to {
+		return strconv.Itoa(r.from)
+	}
+	return fmt.Sprintf("%d..%d", r.from, r.to)
+}
+
+func absIndex(idx int, n int) int {
+	if idx < 0 {
+		return n + idx
+	}
+	return idx - 1
+}
+
+// extract joins the selected fields with a single space
+func (r fieldRange) extract(fields []string) (string, error) {
+	from, to := absIndex(r.from, len(fields)), absIndex(r.to, len(fields))
+	if from < 0 || to >= len(fields) || from > to {
+		return "", fmt.Errorf("out of range: %s", r)
+	}
+	if from == to {
+		return fields[from], nil
+	}
+	return strings.Join(fields[from:to+1], " "), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// resolveFormat turns a format alias into a layout, layouts are returned as is
+func resolveFormat(name string) string {
+	if layout, found := formats[name]; found {
+		return layout
+	}
+	return name
+}
+
+func parseFormats(val string) []string {
+	res := []string{}
+	for _, name := range strings.Split(val, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			res = append(res, resolveFormat(name))
+		}
+	}
+	return res
+}
+
+// formatResolver parses dates trying formats in order. The format that
+// succeeded last is tried first, so a stream with mostly one format
+// rarely pays for the others.
+type formatResolver struct {
+	formats []string
+	locale  *localizer
+	last    int
+}
+
+func newFormatResolver(formats []string, locale *localizer) *formatResolver {
+	return &formatResolver{formats: formats, locale: locale}
+}
+
+func (r *formatResolver) parseAs(val string, layout string) (time.Time, error) {
+	return parseDate(r.locale.translate(val, layout), layout)
+}
+
+func (r *formatResolver) parse(val string) (time.Time, error) {
+	dt, err := r.parseAs(val, r.formats[r.last])
+	if err == nil || len(r.formats) == 1 {
+		return dt, err
+	}
+	for i, layout := range r.formats {
+		if i == r.last {
+			continue
+		}
+		if dt, err := r.parseAs(val, layout); err == nil {
+			r.last = i
+			return dt, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%s: does not match any of the formats", val)
+}
@@ -0,0 +1,5 @@
+module github.com/nchern/cli-tools/dtf
+
+go 1.15
+
+require github.com/klauspost/compress v1.17.0
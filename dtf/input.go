@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	stdinName = "-"
+
+	// linesPerBlock is how many input lines are filtered by a worker at once
+	linesPerBlock = 4096
+
+	// readBufferSize is large enough for a block to rarely end early
+	// because the read buffer runs dry in the middle of a file
+	readBufferSize = 256 * 1024
+
+	// blocksPerInput limits how far an input can run ahead of the one being printed
+	blocksPerInput = 16
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// chunk is a run of consecutive input lines filtered by a worker
+type chunk struct {
+	lines []string
+	// first is the number of the first line in the input
+	first  int
+	prefix string
+	// skew is the correction estimated by the time the chunk was read
+	skew time.Duration
+
+	done chan *block
+}
+
+// block holds filtered output of a chunk
+type block struct {
+	out  bytes.Buffer
+	diag bytes.Buffer
+	err  error
+}
+
+// ready returns a channel with an already known result
+func ready(b *block) chan *block {
+	done := make(chan *block, 1)
+	done <- b
+	return done
+}
+
+// decompress transparently unpacks gzip and zstd data and returns r content as is otherwise
+func decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		dec, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	}
+	return ioutil.NopCloser(br), nil
+}
+
+func openInput(name string) (io.ReadCloser, error) {
+	if name == stdinName {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// filterChunk filters lines of a chunk, it runs on a worker
+func filterChunk(c *chunk, args *Args) *block {
+	b := &block{}
+	st := newStreamState(args)
+	st.skew = c.skew
+	for i, line := range c.lines {
+		if err := processLine(&b.out, line, args, st); err != nil && args.Verbose {
+			fmt.Fprintf(&b.diag, "%s%d: %s\n", c.prefix, c.first+i, err)
+		}
+	}
+	return b
+}
+
+// readInput decompresses an input and splits it into chunks for the workers,
+// results of the chunks are sent to out in the order of the input
+func readInput(name string, args *Args, work chan<- *chunk, out chan<- chan *block) {
+	defer close(out)
+
+	f, err := openInput(name)
+	if err != nil {
+		out <- ready(&block{err: err})
+		return
+	}
+	defer f.Close()
+
+	r, err := decompress(f)
+	if err != nil {
+		out <- ready(&block{err: fmt.Errorf("%s: %v", name, err)})
+		return
+	}
+	defer r.Close()
+
+	prefix := ""
+	if name != stdinName {
+		prefix = name + ":"
+	}
+	// st only estimates the skew: it has to be learned from the first lines
+	// of the input, before they are spread over the workers
+	st := newStreamState(args)
+	c := &chunk{first: 1, prefix: prefix}
+	send := func() {
+		c.skew = st.skew
+		c.done = make(chan *block, 1)
+		work <- c
+		out <- c.done
+	}
+
+	i := 1
+	br := bufio.NewReaderSize(r, readBufferSize)
+	for {
+		if br.Buffered() == 0 && len(c.lines) > 0 {
+			// the next read may block on a live stream: do not hold back what is read
+			send()
+			c = &chunk{first: i, prefix: prefix}
+		}
+		line, err := br.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			st.sample(line, args)
+			c.lines = append(c.lines, line)
+			if len(c.lines) == linesPerBlock {
+				send()
+				c = &chunk{first: i + 1, prefix: prefix}
+			}
+			i++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if len(c.lines) > 0 {
+				send()
+			}
+			out <- ready(&block{err: fmt.Errorf("%s: %v", name, err)})
+			return
+		}
+	}
+	if len(c.lines) > 0 {
+		send()
+	}
+}
+
+// filterInputs filters all inputs with args.Jobs workers, chunks of the same
+// input are filtered in parallel too, and writes the results in the order
+// the inputs were given
+func filterInputs(stdout io.Writer, stderr io.Writer, args *Args) error {
+	names := args.Files
+	if len(names) == 0 {
+		names = []string{stdinName}
+	}
+
+	work := make(chan *chunk, args.Jobs)
+	for n := 0; n < args.Jobs; n++ {
+		go func() {
+			for c := range work {
+				c.done <- filterChunk(c, args)
+			}
+		}()
+	}
+
+	results := make([]chan chan *block, len(names))
+	for i := range results {
+		results[i] = make(chan chan *block, blocksPerInput)
+	}
+
+	// inputs are started strictly in order so that the one being printed
+	// always holds a slot and the pipeline can not stall
+	go func() {
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, args.Jobs)
+		for i, name := range names {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(name string, out chan<- chan *block) {
+				defer func() { <-slots; wg.Done() }()
+				readInput(name, args, work, out)
+			}(name, results[i])
+		}
+		wg.Wait()
+		close(work)
+	}()
+
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+	for _, res := range results {
+		for done := range res {
+			b := <-done
+			if _, err := w.Write(b.out.Bytes()); err != nil {
+				return err
+			}
+			// blocks of a live stream have to show up as soon as they are filtered
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			stderr.Write(b.diag.Bytes())
+			if b.err != nil {
+				return b.err
+			}
+		}
+	}
+	return nil
+}
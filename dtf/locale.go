@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// localeNames lists localized month and weekday names. Each entry holds
+// all spellings of a month (starting with January) or a weekday (starting
+// with Sunday) as they appear in logs: full, abbreviated, declined.
+type localeNames struct {
+	months [12][]string
+	days   [7][]string
+}
+
+var locales = map[string]*localeNames{
+	"de": {
+		months: [12][]string{
+			{"januar", "jan"}, {"februar", "feb"}, {"märz", "mär", "mrz"}, {"april", "apr"},
+			{"mai"}, {"juni", "jun"}, {"juli", "jul"}, {"august", "aug"},
+			{"september", "sep", "sept"}, {"oktober", "okt"}, {"november", "nov"}, {"dezember", "dez"},
+		},
+		days: [7][]string{
+			{"sonntag", "so"}, {"montag", "mo"}, {"dienstag", "di"}, {"mittwoch", "mi"},
+			{"donnerstag", "do"}, {"freitag", "fr"}, {"samstag", "sa"},
+		},
+	},
+	"fr": {
+		months: [12][]string{
+			{"janvier", "janv."}, {"février", "févr."}, {"mars"}, {"avril", "avr."},
+			{"mai"}, {"juin"}, {"juillet", "juil."}, {"août"},
+			{"septembre", "sept."}, {"octobre", "oct."}, {"novembre", "nov."}, {"décembre", "déc."},
+		},
+		days: [7][]string{
+			{"dimanche", "dim."}, {"lundi", "lun."}, {"mardi", "mar."}, {"mercredi", "mer."},
+			{"jeudi", "jeu."}, {"vendredi", "ven."}, {"samedi", "sam."},
+		},
+	},
+	"es": {
+		months: [12][]string{
+			{"enero", "ene"}, {"febrero", "feb"}, {"marzo", "mar"}, {"abril", "abr"},
+			{"mayo", "may"}, {"junio", "jun"}, {"julio", "jul"}, {"agosto", "ago"},
+			{"septiembre", "sep", "sept"}, {"octubre", "oct"}, {"noviembre", "nov"}, {"diciembre", "dic"},
+		},
+		days: [7][]string{
+			{"domingo", "dom"}, {"lunes", "lun"}, {"martes", "mar"}, {"miércoles", "mié"},
+			{"jueves", "jue"}, {"viernes", "vie"}, {"sábado", "sáb"},
+		},
+	},
+	"ru": {
+		months: [12][]string{
+			{"январь", "января", "янв"}, {"февраль", "февраля", "фев"}, {"март", "марта", "мар"},
+			{"апрель", "апреля", "апр"}, {"май", "мая"}, {"июнь", "июня", "июн"},
+			{"июль", "июля", "июл"}, {"август", "августа", "авг"}, {"сентябрь", "сентября", "сен"},
+			{"октябрь", "октября", "окт"}, {"ноябрь", "ноября", "ноя"}, {"декабрь", "декабря", "дек"},
+		},
+		days: [7][]string{
+			{"воскресенье", "вс"}, {"понедельник", "пн"}, {"вторник", "вт"}, {"среда", "ср"},
+			{"четверг", "чт"}, {"пятница", "пт"}, {"суббота", "сб"},
+		},
+	},
+}
+
+// localizer rewrites localized month and weekday names into English ones Go layouts understand
+type localizer struct {
+	months map[string]time.Month
+	days   map[string]time.Weekday
+}
+
+func localeList() string {
+	names := []string{}
+	for name := range locales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func newLocalizer(name string) (*localizer, error) {
+	if name == "" || name == "en" {
+		return nil, nil
+	}
+	names, found := locales[name]
+	if !found {
+		return nil, fmt.Errorf("%s: unsupported locale, use one of: %s", name, localeList())
+	}
+	l := &localizer{months: map[string]time.Month{}, days: map[string]time.Weekday{}}
+	for i, spellings := range names.months {
+		for _, s := range spellings {
+			l.months[s] = time.Month(i + 1)
+		}
+	}
+	for i, spellings := range names.days {
+		for _, s := range spellings {
+			l.days[s] = time.Weekday(i)
+		}
+	}
+	return l, nil
+}
+
+func shortName(s string, layout string, full string) string {
+	if strings.Contains(layout, full) {
+		return s
+	}
+	return s[:3]
+}
+
+// translate replaces localized names in val with English ones
+// in the form (full or abbreviated) the layout expects
+func (l *localizer) translate(val string, layout string) string {
+	wantMonth := strings.Contains(layout, "Jan")
+	wantDay := strings.Contains(layout, "Mon")
+	if l == nil || (!wantMonth && !wantDay) {
+		return val
+	}
+
+	// names in val are matched with the month and weekday of the layout in order
+	slots := nameSlots(layout)
+	slot := 0
+	lookup := func(word string) (string, bool) {
+		preferMonth := wantMonth
+		if slot < len(slots) {
+			preferMonth = slots[slot]
+		}
+		en, ok := l.lookup(word, layout, wantMonth, wantDay, preferMonth)
+		if ok {
+			slot++
+		}
+		return en, ok
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(val); {
+		r, size := utf8.DecodeRuneInString(val[i:])
+		if !unicode.IsLetter(r) {
+			b.WriteString(val[i : i+size])
+			i += size
+			continue
+		}
+		j := i
+		for j < len(val) {
+			r, size := utf8.DecodeRuneInString(val[j:])
+			if !unicode.IsLetter(r) {
+				break
+			}
+			j += size
+		}
+		// abbreviations may end with a dot that is a part of the name
+		word := strings.ToLower(val[i:j])
+		if j < len(val) && val[j] == '.' {
+			if en, ok := lookup(word + "."); ok {
+				b.WriteString(en)
+				i = j + 1
+				continue
+			}
+		}
+		if en, ok := lookup(word); ok {
+			b.WriteString(en)
+		} else {
+			b.WriteString(val[i:j])
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// nameSlots lists kinds of names in the layout in their order: true for a month, false for a weekday
+func nameSlots(layout string) []bool {
+	month, day := strings.Index(layout, "Jan"), strings.Index(layout, "Mon")
+	switch {
+	case month < 0 && day < 0:
+		return nil
+	case month < 0:
+		return []bool{false}
+	case day < 0:
+		return []bool{true}
+	case month < day:
+		return []bool{true, false}
+	}
+	return []bool{false, true}
+}
+
+// lookup translates a month or weekday name, a word that is both,
+// like Spanish "mar", is taken as preferMonth says
+func (l *localizer) lookup(word string, layout string, wantMonth bool, wantDay bool, preferMonth bool) (string, bool) {
+	m, isMonth := l.months[word]
+	d, isDay := l.days[word]
+	isMonth = isMonth && wantMonth
+	isDay = isDay && wantDay
+	if isMonth && (preferMonth || !isDay) {
+		return shortName(m.String(), layout, "January"), true
+	}
+	if isDay {
+		return shortName(d.String(), layout, "Monday"), true
+	}
+	return "", false
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -54,63 +55,136 @@ func parseDate(val, dateFmt string) (time.Time, error) {
 }
 
 type Args struct {
-	Since    time.Time
-	Until    time.Time
-	Format   string
-	FieldIdx int
-	Verbose  bool
+	Since   time.Time
+	Until   time.Time
+	Formats []string
+	Locale  *localizer
+	Fields  fieldRange
+	Skew    skewSpec
+	Verbose bool
+	Jobs    int
+	Output  string
+	Probe   int
+	Files   []string
 }
 
 func parseArgs() (*Args, error) {
 	since := flag.String("since", "", "start period")
 	until := flag.String("until", "now", "end period")
 	dateFmt := flag.String("format", "2006-01-02T15:04:05", "date and time format")
-	fieldIdx := flag.String("f", "1", "index of the date time field, starts with 1")
+	dateFmts := flag.String("formats", "",
+		"comma separated list of date and time formats tried in order, for inputs mixing several formats; overrides -format")
+	locale := flag.String("locale", "", "language of month and weekday names in dates: "+localeList())
+	fieldIdx := flag.String("f", "1", "index of the date time field, starts with 1; negative ones count from the end, -1 is the last field")
+	fieldsRange := flag.String("fields", "",
+		"range of fields a..b joined with a space when the date time spans several fields, e.g. 1..2; overrides -f")
+	skew := flag.String("skew", "", "correction added to parsed timestamps before comparison, e.g. -2m30s for a clock "+
+		"running ahead; "+skewAuto+" estimates it from the reference timestamps in -skew-ref fields")
+	skewRef := flag.String("skew-ref", "", "index or range a..b of fields with timestamps of a trusted clock, for -skew "+skewAuto)
 	verbose := flag.Bool("v", false, "print out all line processing errors")
+	output := flag.String("o", outputText, "output format: "+outputText+" prints kept lines as is, "+
+		outputJSON+" prints a JSON object with parsed timestamp, line and its fields per kept line")
+	jobs := flag.Int("j", runtime.NumCPU(), "number of workers filtering input files and blocks of their lines in parallel")
+	probeLines := flag.Int("probe", 0, "sample this many first lines of the first input, report which fields "+
+		"parse as dates with which formats and suggest -f and -format instead of filtering")
 	flag.Parse()
 
-	parsedSince, err := parseDate(*since, *dateFmt)
+	layouts := []string{resolveFormat(*dateFmt)}
+	if *dateFmts != "" {
+		layouts = parseFormats(*dateFmts)
+		if len(layouts) == 0 {
+			return nil, fmt.Errorf("-formats: no formats given")
+		}
+	}
+
+	loc, err := newLocalizer(*locale)
+	if err != nil {
+		return nil, err
+	}
+
+	if *probeLines < 0 {
+		return nil, fmt.Errorf("-probe %d should not be negative", *probeLines)
+	}
+	if *probeLines > 0 {
+		// the period and field flags are what probing helps to figure out
+		return &Args{Formats: layouts, Locale: loc, Probe: *probeLines, Files: flag.Args()}, nil
+	}
+
+	parsedSince, err := newFormatResolver(layouts, loc).parse(*since)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing --since: %v", err)
 	}
 
 	parsedUntil := time.Now().Local()
 	if *until != "now" {
-		parsedUntil, err = parseDate(*until, *dateFmt)
+		parsedUntil, err = newFormatResolver(layouts, loc).parse(*until)
 		if err != nil {
 			return nil, fmt.Errorf("error parsing --until: %v", err)
 		}
 	}
 
-	idx, err := strconv.Atoi(*fieldIdx)
-	if err != nil || idx <= 0 {
-		return nil, fmt.Errorf("%s should be greater than zero", *fieldIdx)
+	rangeSpec := *fieldIdx
+	if *fieldsRange != "" {
+		rangeSpec = *fieldsRange
+	}
+	fields, err := parseFieldRange(rangeSpec)
+	if err != nil {
+		return nil, err
 	}
-	idx--
 
-	return &Args{Since: parsedSince, Until: parsedUntil, Format: *dateFmt, FieldIdx: idx, Verbose: *verbose}, nil
+	skewCorrection, err := parseSkew(*skew, *skewRef)
+	if err != nil {
+		return nil, err
+	}
+
+	if *jobs <= 0 {
+		return nil, fmt.Errorf("-j %d should be greater than zero", *jobs)
+	}
+
+	if *output != outputText && *output != outputJSON {
+		return nil, fmt.Errorf("-o %s: unknown output format", *output)
+	}
+
+	return &Args{
+		Since:   parsedSince,
+		Until:   parsedUntil,
+		Formats: layouts,
+		Locale:  loc,
+		Fields:  fields,
+		Skew:    skewCorrection,
+		Verbose: *verbose,
+		Jobs:    *jobs,
+		Output:  *output,
+		Files:   flag.Args(),
+	}, nil
 }
 
-func shouldSkip(fields []string, args *Args) (bool, error) {
-	if args.FieldIdx < 0 || args.FieldIdx >= len(fields) {
-		return true, fmt.Errorf("out of range: %d", args.FieldIdx)
+func shouldSkip(fields []string, args *Args, st *streamState) (time.Time, bool, error) {
+	val, err := args.Fields.extract(fields)
+	if err != nil {
+		return time.Time{}, true, err
 	}
-	dt, err := parseDate(fields[args.FieldIdx], args.Format)
+	dt, err := st.formats.parse(val)
 	if err != nil {
-		return true, err
+		return dt, true, err
 	}
-	return dt.Before(args.Since) || dt.After(args.Until), nil
+	dt = st.correct(dt, args.Skew)
+	return dt, dt.Before(args.Since) || dt.After(args.Until), nil
 }
 
-func processLine(line string, args *Args) error {
+func processLine(w io.Writer, line string, args *Args, st *streamState) error {
 	fields := strings.Fields(line)
-	skip, err := shouldSkip(fields, args)
+	dt, skip, err := shouldSkip(fields, args, st)
 	if err != nil {
 		return fmt.Errorf("warn: problem: %v, skipping line: %s", err, line)
 	}
-	if !skip {
-		fmt.Println(line)
+	if skip {
+		return nil
 	}
+	if args.Output == outputJSON {
+		return writeJSON(w, dt, line, fields)
+	}
+	fmt.Fprintln(w, line)
 	return nil
 }
 
@@ -119,16 +193,10 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("parse args: %v", err)
 	}
-	i := 1
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if err := processLine(line, args); err != nil && args.Verbose {
-			fmt.Fprintf(os.Stderr, "%d: %s\n", i, err)
-		}
-		i++
+	if args.Probe > 0 {
+		return probeInput(os.Stdout, args)
 	}
-	return scanner.Err()
+	return filterInputs(os.Stdout, os.Stderr, args)
 }
 
 func main() {
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// record is a kept line in JSON output
+type record struct {
+	Timestamp string   `json:"ts"`
+	Line      string   `json:"line"`
+	Fields    []string `json:"fields"`
+}
+
+func writeJSON(w io.Writer, dt time.Time, line string, fields []string) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	// Encode appends a new line, so each record takes exactly one line
+	return enc.Encode(&record{
+		Timestamp: dt.Format(time.RFC3339Nano),
+		Line:      line,
+		Fields:    fields,
+	})
+}
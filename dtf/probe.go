@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// probeLayouts are tried by -probe in addition to the formats given with -format or -formats
+var probeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.000",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+	time.Stamp,
+	time.StampMilli,
+	"[02/Jan/2006:15:04:05",
+	"02/Jan/2006:15:04:05",
+	time.RFC1123,
+	time.UnixDate,
+	"15:04:05",
+}
+
+// probeHit counts sampled lines where the fields parse with the layout
+type probeHit struct {
+	fields fieldRange
+	layout string
+	n      int
+}
+
+func probeCandidates(args *Args) []string {
+	seen := map[string]bool{}
+	res := []string{}
+	for _, layouts := range [][]string{args.Formats, probeLayouts} {
+		for _, layout := range layouts {
+			if !seen[layout] {
+				seen[layout] = true
+				res = append(res, layout)
+			}
+		}
+	}
+	return res
+}
+
+// probe samples the first lines of r and reports fields that parse as dates
+// along with the formats they parse with, the best match is suggested as flags
+func probe(r io.Reader, w io.Writer, args *Args) error {
+	lines := [][]string{}
+	scanner := bufio.NewScanner(r)
+	for len(lines) < args.Probe && scanner.Scan() {
+		lines = append(lines, strings.Fields(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("probe: no lines to sample")
+	}
+
+	// the widest line bounds positions a timestamp can start at
+	maxFields := 0
+	for _, fields := range lines {
+		if len(fields) > maxFields {
+			maxFields = len(fields)
+		}
+	}
+
+	hits := []*probeHit{}
+	for _, layout := range probeCandidates(args) {
+		width := len(strings.Fields(layout))
+		if width == 0 {
+			continue
+		}
+		for from := 1; from+width-1 <= maxFields; from++ {
+			hit := &probeHit{fields: fieldRange{from: from, to: from + width - 1}, layout: layout}
+			for _, fields := range lines {
+				val, err := hit.fields.extract(fields)
+				if err != nil {
+					continue
+				}
+				if _, err := time.ParseInLocation(layout, args.Locale.translate(val, layout), time.Local); err == nil {
+					hit.n++
+				}
+			}
+			if hit.n > 0 {
+				hits = append(hits, hit)
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return fmt.Errorf("probe: no fields parse as dates in %d sampled lines, try -format", len(lines))
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].n != hits[j].n {
+			return hits[i].n > hits[j].n
+		}
+		// a date and time beats the date alone
+		wi, wj := hits[i].fields.to-hits[i].fields.from, hits[j].fields.to-hits[j].fields.from
+		if wi != wj {
+			return wi > wj
+		}
+		return hits[i].fields.from < hits[j].fields.from
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELDS\tFORMAT\tPARSED")
+	for _, hit := range hits {
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\n", hit.fields, hit.layout, hit.n, len(lines))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	best := hits[0]
+	flag := "-f"
+	if best.fields.from != best.fields.to {
+		flag = "-fields"
+	}
+	_, err := fmt.Fprintf(w, "\nsuggested: %s %s -format '%s'\n", flag, best.fields, best.layout)
+	return err
+}
+
+// probeInput samples the first input
+func probeInput(w io.Writer, args *Args) error {
+	name := stdinName
+	if len(args.Files) > 0 {
+		name = args.Files[0]
+	}
+	f, err := openInput(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := decompress(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	defer r.Close()
+	return probe(r, w, args)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	skewAuto = "auto"
+
+	// skewSamples is how many first lines of an input are used to estimate the skew
+	skewSamples = 16
+)
+
+// skewSpec tells how to correct timestamps of machines with drifting clocks
+type skewSpec struct {
+	// fixed is added to every parsed timestamp
+	fixed time.Duration
+
+	// auto estimates the correction comparing timestamps with the ones in the ref fields
+	auto bool
+	ref  fieldRange
+}
+
+func parseSkew(val string, ref string) (skewSpec, error) {
+	if val == "" {
+		return skewSpec{}, nil
+	}
+	if val == skewAuto {
+		if ref == "" {
+			return skewSpec{}, fmt.Errorf("-skew %s requires -skew-ref", skewAuto)
+		}
+		fields, err := parseFieldRange(ref)
+		if err != nil {
+			return skewSpec{}, fmt.Errorf("-skew-ref: %v", err)
+		}
+		return skewSpec{auto: true, ref: fields}, nil
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return skewSpec{}, fmt.Errorf("-skew: %v", err)
+	}
+	return skewSpec{fixed: d}, nil
+}
+
+// streamState holds what is learned about an input while it is read
+type streamState struct {
+	formats    *formatResolver
+	refFormats *formatResolver
+
+	samples []time.Duration
+	skew    time.Duration
+}
+
+func newStreamState(args *Args) *streamState {
+	return &streamState{
+		formats:    newFormatResolver(args.Formats, args.Locale),
+		refFormats: newFormatResolver(args.Formats, args.Locale),
+	}
+}
+
+// sample learns the auto skew correction from a line of the input: it is the median
+// difference between the reference and the parsed timestamps over the first
+// lines where both are present
+func (s *streamState) sample(line string, args *Args) {
+	if !args.Skew.auto || len(s.samples) >= skewSamples {
+		return
+	}
+	fields := strings.Fields(line)
+	val, err := args.Fields.extract(fields)
+	if err != nil {
+		return
+	}
+	dt, err := s.formats.parse(val)
+	if err != nil {
+		return
+	}
+	if val, err = args.Skew.ref.extract(fields); err != nil {
+		return
+	}
+	if ref, err := s.refFormats.parse(val); err == nil {
+		s.samples = append(s.samples, ref.Sub(dt))
+		s.skew = median(s.samples)
+	}
+}
+
+// correct applies the skew correction to a parsed timestamp
+func (s *streamState) correct(dt time.Time, spec skewSpec) time.Time {
+	if !spec.auto {
+		return dt.Add(spec.fixed)
+	}
+	return dt.Add(s.skew)
+}
+
+func median(vals []time.Duration) time.Duration {
+	sorted := append([]time.Duration{}, vals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
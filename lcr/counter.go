@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Counter tallies lines matching a named pattern
+type Counter struct {
+	name    string
+	matcher Matcher
+	n       int
+}
+
+// Counters implements flag.Value to collect counters from repeated flags
+type Counters []*Counter
+
+func (c *Counters) String() string {
+	names := []string{}
+	for _, counter := range *c {
+		names = append(names, counter.name)
+	}
+	return strings.Join(names, ",")
+}
+
+// Set adds a counter given as "name=regexp" or counters given as comma
+// separated words, which count lines containing them regardless of case.
+// Regexps are kept whole as they may contain commas themselves.
+func (c *Counters) Set(s string) error {
+	if i := strings.Index(s, "="); i >= 0 {
+		if s[:i] == "" || s[i+1:] == "" {
+			return fmt.Errorf("%s: expected name=regexp or comma separated words", s)
+		}
+		return c.add(s[:i], s[i+1:])
+	}
+	words := strings.Split(s, ",")
+	for _, word := range words {
+		if word == "" {
+			return fmt.Errorf("%s: expected name=regexp or comma separated words", s)
+		}
+	}
+	for _, word := range words {
+		if err := c.add(word, "(?i)"+regexp.QuoteMeta(word)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Counters) add(name string, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	*c = append(*c, &Counter{name: name, matcher: &RegexpMatcher{re}})
+	return nil
+}
+
+func (c Counters) tally(line string) {
+	for _, counter := range c {
+		if counter.matcher.Match(line) {
+			counter.n++
+		}
+	}
+}
+
+func (c Counters) status() string {
+	items := make([]string, len(c))
+	for i, counter := range c {
+		items[i] = fmt.Sprintf("%s: %d", counter.name, counter.n)
+	}
+	return strings.Join(items, "  ")
+}
+
+// StatusLine keeps a single line of text at the bottom of a terminal
+// while other output scrolls above it
+type StatusLine struct {
+	w     io.Writer
+	drawn bool
+}
+
+// isTerminal tells a terminal from other character devices like /dev/null
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// Clear removes the status line, it is called before writing other output
+func (s *StatusLine) Clear() {
+	if s.drawn {
+		fmt.Fprint(s.w, "\r\033[K")
+		s.drawn = false
+	}
+}
+
+// Draw replaces the status line with text
+func (s *StatusLine) Draw(text string) {
+	fmt.Fprint(s.w, "\r\033[K", colorize256(text, grey))
+	s.drawn = true
+}
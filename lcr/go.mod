@@ -0,0 +1,5 @@
+module github.com/nchern/cli-tools/lcr
+
+go 1.15
+
+require golang.org/x/term v0.13.0
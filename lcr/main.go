@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -9,6 +10,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 const (
@@ -31,6 +33,9 @@ const (
 	darkYellow  Color = 178
 	darkGreen   Color = 41
 	lightGreen  Color = 48
+	brightGreen Color = 46
+	lightBlue   Color = 75
+	grey        Color = 244
 
 	darkOrange Color = 202
 )
@@ -42,6 +47,12 @@ type Color byte
 type Entity struct {
 	color   Color
 	matcher Matcher
+
+	// priority resolves overlapping matches: entities with higher priority are tried first
+	priority int
+
+	// requires lists characters any matching token contains
+	requires CharClass
 }
 
 type Matcher interface {
@@ -59,6 +70,14 @@ func (m RegexpMatcher) Match(s string) bool { return m.MatchString(s) }
 type NumberMatcher struct{}
 
 func (m *NumberMatcher) Match(s string) bool {
+	// most tokens are words: reject them before ParseFloat, which
+	// only accepts letters in NaN, Inf and exponents
+	if t := strings.TrimLeft(s, "+-"); t != "" {
+		switch c := t[0] | 0x20; {
+		case c >= 'a' && c <= 'z' && c != 'i' && c != 'n':
+			return false
+		}
+	}
 	_, err := strconv.ParseFloat(s, 32)
 	return err == nil
 }
@@ -75,32 +94,46 @@ var (
 
 	entities = map[string]*Entity{
 		"number": {
-			color:   darkYellow,
-			matcher: &NumberMatcher{},
+			priority: 10,
+			color:    darkYellow,
+			matcher:  &NumberMatcher{},
+			// nothing is required as NaN and Inf are numbers too
 		},
 		"error": {
-			color:   orange,
-			matcher: NewRegexpMatcher("(?i)error[:]?"),
+			priority: 30,
+			color:    orange,
+			matcher:  LiteralMatcher("error"),
+			requires: classLetter,
 		},
 		"time": {
-			color:   lightGreen,
-			matcher: NewRegexpMatcher(`[0-9]{2}:[0-9]{2}:[0-9]{2}(\+[0-9]+?){0,1}$`),
+			priority: 50,
+			color:    lightGreen,
+			matcher:  NewRegexpMatcher(`[0-9]{2}:[0-9]{2}:[0-9]{2}(\+[0-9]+?){0,1}$`),
+			requires: classDigit | classColon,
 		},
 		"date": {
-			color:   lightGreen,
-			matcher: NewRegexpMatcher(`[0-9]{4}/[0-9]{2}/[0-9]{2}$`),
+			priority: 50,
+			color:    lightGreen,
+			matcher:  NewRegexpMatcher(`[0-9]{4}/[0-9]{2}/[0-9]{2}$`),
+			requires: classDigit | classSlash,
 		},
 		"iso_date_time": {
-			color:   lightGreen,
-			matcher: NewRegexpMatcher(`[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\+[0-9]+?){0,1}$`),
+			priority: 60,
+			color:    lightGreen,
+			requires: classDigit | classDash | classColon,
+			matcher:  NewRegexpMatcher(`[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\+[0-9]+?){0,1}$`),
 		},
 		"ip_v4_addr": {
-			color:   darkGreen,
-			matcher: NewRegexpMatcher(`[0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3}`),
+			priority: 40,
+			color:    darkGreen,
+			requires: classDigit | classDot,
+			matcher:  NewRegexpMatcher(`[0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3}\.[0-9]{1,3}`),
 		},
 		"ip_v6_addr": {
-			color:   darkGreen,
-			matcher: NewRegexpMatcher(`^([0-9a-fA-F]{1,4}:){7}|(([0-9a-fA-F]{1,4}:){6}(:[0-9a-fA-F]{1,4}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))?|(([0-9a-fA-F]{1,4}:){5}:([0-9a-fA-F]{1,4})?)|(([0-9a-fA-F]{1,4}:){4}:(:[0-9a-fA-F]{1,4}){0,2}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))|(([0-9a-fA-F]{1,4}:){3}:(:[0-9a-fA-F]{1,4}){0,3}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))|(([0-9a-fA-F]{1,4}:){2}:(:[0-9a-fA-F]{1,4}){0,4}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))|(([0-9a-fA-F]{1,4}:){1}:([0-9a-fA-F]{1,4}){0,5}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3})))$`),
+			priority: 40,
+			color:    darkGreen,
+			requires: classColon,
+			matcher:  NewRegexpMatcher(`^([0-9a-fA-F]{1,4}:){7}|(([0-9a-fA-F]{1,4}:){6}(:[0-9a-fA-F]{1,4}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))?|(([0-9a-fA-F]{1,4}:){5}:([0-9a-fA-F]{1,4})?)|(([0-9a-fA-F]{1,4}:){4}:(:[0-9a-fA-F]{1,4}){0,2}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))|(([0-9a-fA-F]{1,4}:){3}:(:[0-9a-fA-F]{1,4}){0,3}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))|(([0-9a-fA-F]{1,4}:){2}:(:[0-9a-fA-F]{1,4}){0,4}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3}))|(([0-9a-fA-F]{1,4}:){1}:([0-9a-fA-F]{1,4}){0,5}|((25[0-5]|2[0-4][0-9]|[01]?[0-9]{1,2})\.){3}([0-9]{1,3})))$`),
 		},
 	}
 )
@@ -130,37 +163,201 @@ func tokenize(line string) <-chan string {
 	return toks
 }
 
-func process(r io.Reader) error {
+func colorizeLine(line string, classifier *Classifier, preset *Preset) string {
+	if preset != nil {
+		for _, entity := range preset.lines {
+			if entity.matcher.Match(line) {
+				return colorize256(line, entity.color)
+			}
+		}
+	}
+	toks := []string{}
+	for cur := range tokenize(line) {
+		if entity := classifier.Find(cur); entity != nil {
+			cur = colorize256(cur, entity.color)
+		}
+		// highlight **name=** in name=value pattern
+		l := len(toks) - 1
+		if l > -1 && !terminalSymbols[toks[l]] && cur == "=" {
+			toks[l] = colorize256(toks[l], lightPurple)
+			cur = colorize256(cur, lightPurple)
+		}
+		toks = append(toks, cur)
+	}
+	return strings.Join(toks, "")
+}
+
+// Options control what and how gets colorized
+type Options struct {
+	Preset string
+
+	// Disabled holds names of entities not to highlight
+	Disabled map[string]bool
+
+	// Counters are tallied over the input and shown in a status line on stderr
+	Counters Counters
+
+	// Numbers prefixes lines with their numbers in the input
+	Numbers bool
+
+	// Safe escapes control characters of the input, so that only
+	// the colors are interpreted by a terminal or less -R
+	Safe bool
+
+	// Stats prints a summary of warnings and errors on stderr at the end,
+	// with a per-minute heat strip if the output is a terminal
+	Stats bool
+}
+
+func process(r io.Reader, opts *Options) error {
+	preset, err := getPreset(opts.Preset)
+	if err != nil {
+		return err
+	}
+	classifier := NewClassifier(entities, opts.Disabled)
+	if preset != nil {
+		classifier = newPresetClassifier(preset, opts.Disabled)
+	}
+
+	var status *StatusLine
+	if len(opts.Counters) > 0 && isTerminal(os.Stderr) {
+		status = &StatusLine{w: os.Stderr}
+	}
+
+	var stats *Stats
+	if opts.Stats {
+		stats = newStats()
+	}
+
 	scanner := bufio.NewScanner(r)
 	i := -1
 	for scanner.Scan() {
 		i++
-		toks := []string{}
-		for cur := range tokenize(scanner.Text()) {
-			for _, entity := range entities {
-				if entity.matcher.Match(cur) {
-					cur = colorize256(cur, entity.color)
-					break
-				}
-			}
-			// highlight **name=** in name=value pattern
-			l := len(toks) - 1
-			if l > -1 && !terminalSymbols[toks[l]] && cur == "=" {
-				toks[l] = colorize256(toks[l], lightPurple)
-				cur = colorize256(cur, lightPurple)
+		line := scanner.Text()
+		opts.Counters.tally(line)
+		if stats != nil {
+			stats.add(line)
+		}
+		if preset == nil && opts.Preset == presetAuto && i < sniffLines {
+			if preset = detectPreset(line); preset != nil {
+				classifier = newPresetClassifier(preset, opts.Disabled)
 			}
-			toks = append(toks, cur)
 		}
-		line := strings.Join(toks, "")
-		if _, err := fmt.Println(line); err != nil {
+		if status != nil {
+			status.Clear()
+		}
+		if opts.Safe {
+			line = escapeControls(line)
+		}
+		out := colorizeLine(line, classifier, preset)
+		if opts.Numbers {
+			out = colorize256(fmt.Sprintf("%6d", i+1), grey) + " " + out
+		}
+		if _, err := fmt.Println(out); err != nil {
 			return err
 		}
+		if status != nil {
+			status.Draw(opts.Counters.status())
+		}
+	}
+	if len(opts.Counters) > 0 {
+		if status != nil {
+			status.Clear()
+		}
+		fmt.Fprintln(os.Stderr, opts.Counters.status())
+	}
+	if stats != nil {
+		stats.print(os.Stderr, isTerminal(os.Stdout))
 	}
 	return scanner.Err()
 }
 
+var (
+	presetName = flag.String("preset", presetAuto,
+		"extra highlighting for output of a tool: "+strings.Join(presetNames(), ", ")+
+			"; "+presetAuto+" detects it from the first lines, "+presetNone+" disables it")
+
+	counters Counters
+
+	numbers = flag.Bool("numbers", false, "prefix lines with their numbers in the input")
+	stats   = flag.Bool("stats", false, "print warning and error counts on stderr at the end, "+
+		"with a per-minute heat strip of them if the output is a terminal")
+	safe = flag.Bool("safe", false, "escape control characters of the input so that only colors reach the terminal, e.g. for less -R")
+
+	disable = flag.String("disable", "", "comma separated names of entities not to highlight: "+
+		strings.Join(entityNames(), ", "))
+)
+
+func parseOptions() (*Options, error) {
+	known := map[string]bool{}
+	for _, name := range entityNames() {
+		known[name] = true
+	}
+	disabled := map[string]bool{}
+	for _, name := range strings.Split(*disable, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("%s: unknown entity", name)
+		}
+		disabled[name] = true
+	}
+	return &Options{
+		Preset:   *presetName,
+		Disabled: disabled,
+		Counters: counters,
+		Numbers:  *numbers,
+		Safe:     *safe,
+		Stats:    *stats,
+	}, nil
+}
+
+func init() {
+	flag.Var(&counters, "count", "count lines matching a named pattern given as name=regexp or comma separated words, "+
+		"counts are shown live on stderr; can be repeated")
+
+	usage := flag.Usage
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "lcr colorizes logs read from stdin to simplify reading them.")
+		fmt.Fprintln(os.Stderr)
+
+		usage()
+	}
+}
+
 func main() {
-	must(process(os.Stdin))
+	flag.Parse()
+	opts, err := parseOptions()
+	must(err)
+	must(process(os.Stdin, opts))
+}
+
+// escapeControls replaces control characters but tab with their caret notation: ESC becomes ^[
+func escapeControls(s string) string {
+	if strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case !isControl(r):
+			b.WriteRune(r)
+		case r == 0x7f:
+			b.WriteString("^?")
+		case r < 0x20:
+			b.WriteByte('^')
+			b.WriteRune(r + '@')
+		default:
+			fmt.Fprintf(&b, "\\u%04x", r)
+		}
+	}
+	return b.String()
+}
+
+func isControl(r rune) bool {
+	return r != '\t' && unicode.IsControl(r)
 }
 
 func colorize256(s string, color Color, attrs ...string) string {
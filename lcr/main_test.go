@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var benchLines = []string{
+	"2024-01-02T15:04:05 INFO server started on 10.0.0.12:8080 pid=4242",
+	"2024/01/02 15:04:06 GET /api/v1/users?id=17 200 12.5ms remote=192.168.1.20",
+	"2024-01-02T15:04:07+0000 ERROR failed to connect to fe80::1ff:fe23:4567:890a (attempt 3)",
+	"[worker-7] processed batch=512 items in 0.734s, queue depth 1024",
+	"WARN disk usage above threshold: /var/lib/data 91% used",
+	"stats mean=NaN max=+Inf min=-Inf",
+}
+
+// unfiltered returns entities without the required characters, so that every
+// entity is tried for every token like before the classifier prefiltered them
+func unfiltered() map[string]*Entity {
+	res := map[string]*Entity{}
+	for name, entity := range entities {
+		e := *entity
+		e.requires = 0
+		res[name] = &e
+	}
+	return res
+}
+
+func benchmarkColorizeLine(b *testing.B, classifier *Classifier) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for _, line := range benchLines {
+			colorizeLine(line, classifier, nil)
+		}
+	}
+}
+
+func BenchmarkColorizeLine(b *testing.B) {
+	benchmarkColorizeLine(b, NewClassifier(entities, nil))
+}
+
+func BenchmarkColorizeLineUnfiltered(b *testing.B) {
+	benchmarkColorizeLine(b, NewClassifier(unfiltered(), nil))
+}
+
+func TestColorizeLineUnchangedByPrefilter(t *testing.T) {
+	filtered, all := NewClassifier(entities, nil), NewClassifier(unfiltered(), nil)
+	for _, line := range benchLines {
+		if expected, actual := colorizeLine(line, all, nil), colorizeLine(line, filtered, nil); actual != expected {
+			t.Errorf("%s:\nexpected %q\nactual   %q", line, expected, actual)
+		}
+	}
+}
+
+func TestColorizeLineHighlightsNaNAndInf(t *testing.T) {
+	classifier := NewClassifier(entities, nil)
+	for _, tok := range []string{"NaN", "+Inf", "-Inf", "inf", "1e3"} {
+		if actual := colorizeLine("x "+tok, classifier, nil); !strings.Contains(actual, colorize256(tok, darkYellow)) {
+			t.Errorf("%s is not highlighted as a number: %q", tok, actual)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CharClass is a set of kinds of characters a token consists of
+type CharClass uint8
+
+const (
+	classDigit CharClass = 1 << iota
+	classLetter
+	classColon
+	classDot
+	classSlash
+	classDash
+)
+
+func classify(s string) CharClass {
+	var c CharClass
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case b >= '0' && b <= '9':
+			c |= classDigit
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z':
+			c |= classLetter
+		case b == ':':
+			c |= classColon
+		case b == '.':
+			c |= classDot
+		case b == '/':
+			c |= classSlash
+		case b == '-':
+			c |= classDash
+		}
+	}
+	return c
+}
+
+// LiteralMatcher matches strings containing the literal regardless of case
+type LiteralMatcher string
+
+func (m LiteralMatcher) Match(s string) bool {
+	n := len(m)
+	for i := 0; i+n <= len(s); {
+		if strings.EqualFold(s[i:i+n], string(m)) {
+			return true
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return false
+}
+
+// Classifier finds an entity of a token. A token is classified once
+// and only entities whose required characters it contains are tried,
+// so most tokens never reach the expensive regexp matchers.
+type Classifier struct {
+	entities []*Entity
+}
+
+// NewClassifier builds a classifier over entities except disabled ones.
+// Overlapping matches resolve to the entity with the higher priority,
+// then to the one with the name first in the sorted order.
+func NewClassifier(entities map[string]*Entity, disabled map[string]bool) *Classifier {
+	names := make([]string, 0, len(entities))
+	for name := range entities {
+		if !disabled[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := entities[names[i]], entities[names[j]]
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		return names[i] < names[j]
+	})
+
+	c := &Classifier{}
+	for _, name := range names {
+		c.entities = append(c.entities, entities[name])
+	}
+	return c
+}
+
+// entityNames lists names of common and all preset entities
+func entityNames() []string {
+	names := []string{}
+	for name := range entities {
+		names = append(names, name)
+	}
+	for _, p := range presets {
+		for name := range p.entities {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Find returns the entity matching s or nil if there is none
+func (c *Classifier) Find(s string) *Entity {
+	class := classify(s)
+	if class == 0 {
+		return nil
+	}
+	for _, entity := range c.entities {
+		if class&entity.requires != entity.requires {
+			continue
+		}
+		if entity.matcher.Match(s) {
+			return entity
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// presetPriority puts tool specific entities before the common ones
+	presetPriority = 100
+
+	presetAuto = "auto"
+	presetNone = "none"
+
+	// sniffLines is how many first lines are checked to detect a preset
+	sniffLines = 10
+)
+
+// Preset holds extra highlighting rules for the output of a particular tool
+type Preset struct {
+	// detect matches lines typical for the tool output
+	detect Matcher
+
+	// entities are matched against tokens in addition to the common ones
+	entities map[string]*Entity
+
+	// lines are matched against whole lines, a match colorizes the entire line
+	lines []*Entity
+}
+
+var (
+	// fileLocation matches compiler style locations: main.go:12: or main.c:12:5:
+	fileLocation = &Entity{
+		priority: presetPriority,
+		color:    lightBlue,
+		matcher:  NewRegexpMatcher(`^[\w./-]+\.\w+:[0-9]+(:[0-9]+)?:?$`),
+		requires: classDigit | classColon | classDot,
+	}
+
+	presets = map[string]*Preset{
+		"gotest": {
+			detect: NewRegexpMatcher(`^(=== RUN|--- (PASS|FAIL|SKIP):|(ok|FAIL)\s+\S+\s+[0-9.]+s|PASS$)`),
+			entities: map[string]*Entity{
+				"gotest_pass": {priority: presetPriority, color: brightGreen, matcher: NewRegexpMatcher(`^(PASS|ok):?$`), requires: classLetter},
+				"gotest_fail": {priority: presetPriority, color: orange, matcher: NewRegexpMatcher(`^FAIL:?$`), requires: classLetter},
+				"gotest_skip": {priority: presetPriority, color: darkYellow, matcher: NewRegexpMatcher(`^SKIP:?$`), requires: classLetter},
+				"gotest_loc":  fileLocation,
+			},
+			lines: []*Entity{
+				{color: grey, matcher: NewRegexpMatcher(`^\s*=== (RUN|PAUSE|CONT|NAME)\s`)},
+			},
+		},
+		"pytest": {
+			detect: NewRegexpMatcher(`^=+ (test session starts|FAILURES|ERRORS|short test summary info) =+$`),
+			entities: map[string]*Entity{
+				"pytest_pass": {priority: presetPriority, color: brightGreen, matcher: NewRegexpMatcher(`^(PASSED|XPASS)$`), requires: classLetter},
+				"pytest_fail": {priority: presetPriority, color: orange, matcher: NewRegexpMatcher(`^(FAILED|XFAIL)$`), requires: classLetter},
+				"pytest_skip": {priority: presetPriority, color: darkYellow, matcher: NewRegexpMatcher(`^SKIPPED$`), requires: classLetter},
+				"pytest_loc":  fileLocation,
+			},
+			lines: []*Entity{
+				{color: lightBlue, matcher: NewRegexpMatcher(`^=+ .* =+$`)},
+				{color: orange, matcher: NewRegexpMatcher(`^_+ .* _+$`)},
+			},
+		},
+		"make": {
+			detect: NewRegexpMatcher(`^make(\[[0-9]+\])?: `),
+			entities: map[string]*Entity{
+				"make_loc": fileLocation,
+				"make_warning": {
+					priority: presetPriority,
+					color:    darkYellow,
+					matcher:  NewRegexpMatcher(`^warning:$`),
+					requires: classLetter | classColon,
+				},
+			},
+			lines: []*Entity{
+				{color: orange, matcher: NewRegexpMatcher(`^make(\[[0-9]+\])?: \*\*\*`)},
+				{color: lightBlue, matcher: NewRegexpMatcher(`^make(\[[0-9]+\])?: `)},
+			},
+		},
+		"journalctl": {
+			detect: NewRegexpMatcher(`^-- (Logs begin|Journal begins|Boot [0-9a-f]+|No entries) `),
+			entities: map[string]*Entity{
+				"journalctl_month": {
+					priority: presetPriority,
+					color:    lightGreen,
+					matcher:  NewRegexpMatcher(`^(Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)$`),
+					requires: classLetter,
+				},
+				"journalctl_warning": {
+					priority: presetPriority,
+					color:    darkYellow,
+					matcher:  NewRegexpMatcher(`(?i)^warn(ing)?:?$`),
+					requires: classLetter,
+				},
+				"journalctl_failure": {
+					priority: presetPriority,
+					color:    orange,
+					matcher:  NewRegexpMatcher(`(?i)^(fail(ed|ure)?|denied|critical|emerg(ency)?|alert|panic):?$`),
+					requires: classLetter,
+				},
+			},
+			lines: []*Entity{
+				{color: grey, matcher: NewRegexpMatcher(`^-- .* --$`)},
+			},
+		},
+	}
+)
+
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// detectPreset returns a preset whose output the line looks like or nil
+func detectPreset(line string) *Preset {
+	for _, name := range presetNames() {
+		if p := presets[name]; p.detect.Match(line) {
+			return p
+		}
+	}
+	return nil
+}
+
+func getPreset(name string) (*Preset, error) {
+	if name == presetAuto || name == presetNone {
+		return nil, nil
+	}
+	p, found := presets[name]
+	if !found {
+		return nil, fmt.Errorf("%s: unknown preset", name)
+	}
+	return p, nil
+}
+
+// newPresetClassifier builds a classifier over the common entities and the preset ones
+func newPresetClassifier(p *Preset, disabled map[string]bool) *Classifier {
+	merged := map[string]*Entity{}
+	for name, entity := range entities {
+		merged[name] = entity
+	}
+	for name, entity := range p.entities {
+		merged[name] = entity
+	}
+	return NewClassifier(merged, disabled)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// heatWidth is the maximum number of cells in the heat strip,
+	// longer time spans are squeezed by putting several minutes into a cell
+	heatWidth = 60
+)
+
+const (
+	sevNone = iota
+	sevWarning
+	sevError
+)
+
+var (
+	errorLine   = regexp.MustCompile(`(?i)\b(error|err|fatal|fail(ed|ure)?|panic|crit(ical)?|emerg(ency)?|alert)\b`)
+	warningLine = regexp.MustCompile(`(?i)\bwarn(ing)?\b`)
+
+	// timestamp matches "2006-01-02T15:04:05", "2006/01/02 15:04:05" or just "15:04:05"
+	timestamp = regexp.MustCompile(`(?:([0-9]{4})[-/]([0-9]{2})[-/]([0-9]{2})[T ])?([0-9]{2}):([0-9]{2}):[0-9]{2}`)
+
+	heatBlocks = []rune("▁▂▃▄▅▆▇█")
+)
+
+func severity(line string) int {
+	switch {
+	case errorLine.MatchString(line):
+		return sevError
+	case warningLine.MatchString(line):
+		return sevWarning
+	}
+	return sevNone
+}
+
+// lineMinute returns the minute of the first timestamp found in the line
+func lineMinute(line string) (time.Time, bool) {
+	m := timestamp.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	n := make([]int, len(m))
+	for i, s := range m[1:] {
+		n[i+1], _ = strconv.Atoi(s)
+	}
+	if n[1] == 0 {
+		// time only, the date is unknown
+		n[1], n[2], n[3] = 1, 1, 1
+	}
+	if n[2] < 1 || n[2] > 12 || n[4] > 23 || n[5] > 59 {
+		return time.Time{}, false
+	}
+	return time.Date(n[1], time.Month(n[2]), n[3], n[4], n[5], 0, 0, time.UTC), true
+}
+
+type bucket struct {
+	lines    int
+	warnings int
+	errors   int
+}
+
+func (b *bucket) add(sev int) {
+	b.lines++
+	switch sev {
+	case sevWarning:
+		b.warnings++
+	case sevError:
+		b.errors++
+	}
+}
+
+// Stats tallies lines by severity overall and per minute of their timestamps.
+// Lines without a timestamp are attributed to the last seen one.
+type Stats struct {
+	total   bucket
+	minutes map[time.Time]*bucket
+
+	// cur is the minute of the last seen timestamp, from and to bound all of them
+	cur      time.Time
+	from, to time.Time
+	seen     bool
+
+	// dated is set once a timestamp with a date is seen, time only matches
+	// are ignored after that as they are likely durations like "elapsed 00:00:05"
+	dated bool
+}
+
+func newStats() *Stats {
+	return &Stats{minutes: map[time.Time]*bucket{}}
+}
+
+func (s *Stats) add(line string) {
+	sev := severity(line)
+	s.total.add(sev)
+	if t, ok := lineMinute(line); ok && !(s.dated && t.Year() == 1) {
+		if !s.dated && t.Year() != 1 {
+			// minutes without a date can not be put on the same strip
+			s.minutes = map[time.Time]*bucket{}
+			s.seen = false
+			s.dated = true
+		}
+		if !s.seen || t.Before(s.from) {
+			s.from = t
+		}
+		if !s.seen || t.After(s.to) {
+			s.to = t
+		}
+		s.cur = t
+		s.seen = true
+	}
+	if !s.seen {
+		return
+	}
+	b := s.minutes[s.cur]
+	if b == nil {
+		b = &bucket{}
+		s.minutes[s.cur] = b
+	}
+	b.add(sev)
+}
+
+func (s *Stats) summary() string {
+	return fmt.Sprintf("lines: %d  warnings: %d  errors: %d",
+		s.total.lines, s.total.warnings, s.total.errors)
+}
+
+// heatCell renders a bucket as a block whose height is the share of problem lines
+// colored by the worst severity in it
+func heatCell(b *bucket) string {
+	if b.lines == 0 {
+		return " "
+	}
+	bad := b.warnings + b.errors
+	block := heatBlocks[bad*(len(heatBlocks)-1)/b.lines]
+	switch {
+	case b.errors > 0:
+		return colorize256(string(block), orange)
+	case b.warnings > 0:
+		return colorize256(string(block), darkYellow)
+	}
+	return colorize256(string(block), grey)
+}
+
+func minuteLabel(t time.Time) string {
+	if t.Year() == 1 {
+		return t.Format("15:04")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// heatStrip renders error density per minute, one cell per heatWidth-th of the time span
+func (s *Stats) heatStrip() string {
+	if !s.seen {
+		return ""
+	}
+	span := int(s.to.Sub(s.from)/time.Minute) + 1
+	perCell := (span + heatWidth - 1) / heatWidth
+	cells := make([]bucket, (span+perCell-1)/perCell)
+	for t, b := range s.minutes {
+		c := &cells[int(t.Sub(s.from)/time.Minute)/perCell]
+		c.lines += b.lines
+		c.warnings += b.warnings
+		c.errors += b.errors
+	}
+	var sb strings.Builder
+	sb.WriteString(minuteLabel(s.from) + " ")
+	for i := range cells {
+		sb.WriteString(heatCell(&cells[i]))
+	}
+	sb.WriteString(" " + minuteLabel(s.to))
+	if perCell > 1 {
+		fmt.Fprintf(&sb, "  (%d min per cell)", perCell)
+	}
+	return sb.String()
+}
+
+func (s *Stats) print(w io.Writer, heat bool) {
+	fmt.Fprintln(w, s.summary())
+	if strip := s.heatStrip(); heat && strip != "" {
+		fmt.Fprintln(w, strip)
+	}
+}
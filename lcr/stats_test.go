@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsIgnoresDurationsInDatedLogs(t *testing.T) {
+	var tests = []struct {
+		name  string
+		given []string
+		from  string
+		to    string
+	}{
+		{"dated",
+			[]string{
+				"2024-01-02 10:00:01 INFO started",
+				"2024-01-02 10:05:00 ERROR failed",
+			},
+			"2024-01-02 10:00", "2024-01-02 10:05"},
+		{"duration after a dated line",
+			[]string{
+				"2024-01-02 10:00:01 INFO started",
+				"job done, elapsed 00:00:05",
+				"2024-01-02 10:05:00 ERROR failed",
+			},
+			"2024-01-02 10:00", "2024-01-02 10:05"},
+		{"duration before a dated line",
+			[]string{
+				"elapsed 00:00:05",
+				"2024-01-02 10:00:01 INFO started",
+				"2024-01-02 10:05:00 ERROR failed",
+			},
+			"2024-01-02 10:00", "2024-01-02 10:05"},
+		{"time only",
+			[]string{
+				"10:00:01 INFO started",
+				"10:05:00 ERROR failed",
+			},
+			"10:00", "10:05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStats()
+			for _, line := range tt.given {
+				s.add(line)
+			}
+			if from := minuteLabel(s.from); from != tt.from {
+				t.Errorf("from: expected %s, got %s", tt.from, from)
+			}
+			if to := minuteLabel(s.to); to != tt.to {
+				t.Errorf("to: expected %s, got %s", tt.to, to)
+			}
+			strip := s.heatStrip()
+			if strings.Contains(strip, "per cell") {
+				t.Errorf("%d minutes should fit one per cell: %s", int(s.to.Sub(s.from)/time.Minute), strip)
+			}
+		})
+	}
+}
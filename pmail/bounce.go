@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+var errNotDSN = errors.New("not a delivery status notification")
+
+// recipientStatus is a per-recipient block of a delivery status notification, RFC3464
+type recipientStatus struct {
+	FinalRecipient    string `json:"final_recipient"`
+	OriginalRecipient string `json:"original_recipient,omitempty"`
+	Action            string `json:"action"`
+	Status            string `json:"status"`
+	RemoteMTA         string `json:"remote_mta,omitempty"`
+	DiagnosticCode    string `json:"diagnostic_code,omitempty"`
+}
+
+type bounceReport struct {
+	ReportingMTA string             `json:"reporting_mta,omitempty"`
+	ArrivalDate  string             `json:"arrival_date,omitempty"`
+	Recipients   []*recipientStatus `json:"recipients"`
+
+	OriginalMessageID string `json:"original_message_id,omitempty"`
+	OriginalSubject   string `json:"original_subject,omitempty"`
+}
+
+// fieldValue strips the type from typed DSN fields: "rfc822; user@example.com"
+func fieldValue(h textproto.MIMEHeader, key string) string {
+	v := h.Get(key)
+	if i := strings.Index(v, ";"); i >= 0 && key != "Diagnostic-Code" {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+func parseDeliveryStatus(r io.Reader, report *bounceReport) error {
+	tr := textproto.NewReader(bufio.NewReader(r))
+	first := true
+	for {
+		h, err := tr.ReadMIMEHeader()
+		if len(h) > 0 {
+			if first {
+				report.ReportingMTA = fieldValue(h, "Reporting-MTA")
+				report.ArrivalDate = fieldValue(h, "Arrival-Date")
+				first = false
+			} else {
+				report.Recipients = append(report.Recipients, &recipientStatus{
+					FinalRecipient:    fieldValue(h, "Final-Recipient"),
+					OriginalRecipient: fieldValue(h, "Original-Recipient"),
+					Action:            fieldValue(h, "Action"),
+					Status:            fieldValue(h, "Status"),
+					RemoteMTA:         fieldValue(h, "Remote-MTA"),
+					DiagnosticCode:    fieldValue(h, "Diagnostic-Code"),
+				})
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// bounce extracts failed recipients and diagnostics from a DSN and prints them as JSON
+func bounce(r io.Reader, w io.Writer, args []string) error {
+	report := &bounceReport{Recipients: []*recipientStatus{}}
+	isDSN := false
+	err := walkMessage(r, func(mediaType string, params map[string]string, body io.Reader) error {
+		switch mediaType {
+		case "message/delivery-status", "message/global-delivery-status":
+			isDSN = true
+			return parseDeliveryStatus(body, report)
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			orig, err := mail.ReadMessage(body)
+			if err != nil {
+				// the original message is optional and often truncated
+				return nil
+			}
+			report.OriginalMessageID = orig.Header.Get("Message-Id")
+			report.OriginalSubject = orig.Header.Get("Subject")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !isDSN {
+		return errNotDSN
+	}
+	if len(report.Recipients) == 0 {
+		return fmt.Errorf("no recipients in the delivery status")
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(report)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+)
+
+// maxDiffCells limits the work of diffing bodies line by line, the memory
+// it takes is linear in the number of lines
+const maxDiffCells = 16 * 1024 * 1024
+
+var (
+	// volatileHeaders change on every delivery of the same message
+	volatileHeaders = map[string]bool{
+		"Received":                   true,
+		"X-Received":                 true,
+		"Message-Id":                 true,
+		"Return-Path":                true,
+		"Delivered-To":               true,
+		"Authentication-Results":     true,
+		"Arc-Seal":                   true,
+		"Arc-Message-Signature":      true,
+		"Arc-Authentication-Results": true,
+		"Dkim-Signature":             true,
+	}
+
+	diffFlags     = flag.NewFlagSet(cmdDiff, flag.ExitOnError)
+	diffAllHeader = diffFlags.Bool("all", false, "compare volatile headers (Received, Message-ID, ...) too")
+)
+
+type part struct {
+	mediaType string
+	data      []byte
+}
+
+type message struct {
+	header mail.Header
+	parts  []*part
+}
+
+func readMessage(name string, stdin io.Reader) (*message, error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	m := &message{header: msg.Header}
+	err = walkParts(textproto.MIMEHeader(msg.Header), msg.Body,
+		func(mediaType string, params map[string]string, body io.Reader) error {
+			data, err := ioutil.ReadAll(body)
+			if err != nil {
+				return err
+			}
+			m.parts = append(m.parts, &part{mediaType: mediaType, data: data})
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return m, nil
+}
+
+func diffHeaders(w io.Writer, a, b mail.Header) {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := []string{}
+	for k := range keys {
+		if *diffAllHeader || !volatileHeaders[k] {
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		va, vb := a[k], b[k]
+		if strings.Join(va, "\n") == strings.Join(vb, "\n") {
+			continue
+		}
+		fmt.Fprintf(w, "header %s\n", k)
+		diffLines(w, va, vb)
+	}
+}
+
+func diffParts(w io.Writer, a, b []*part) {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		switch {
+		case i >= len(b):
+			fmt.Fprintf(w, "part %d %s\n- only in the first message\n", i+1, a[i].mediaType)
+		case i >= len(a):
+			fmt.Fprintf(w, "part %d %s\n+ only in the second message\n", i+1, b[i].mediaType)
+		case a[i].mediaType != b[i].mediaType:
+			fmt.Fprintf(w, "part %d\n- %s\n+ %s\n", i+1, a[i].mediaType, b[i].mediaType)
+		case bytes.Equal(a[i].data, b[i].data):
+		case strings.HasPrefix(a[i].mediaType, "text/"):
+			fmt.Fprintf(w, "part %d %s\n", i+1, a[i].mediaType)
+			diffLines(w, splitLines(a[i].data), splitLines(b[i].data))
+		default:
+			fmt.Fprintf(w, "part %d %s\n- %d bytes\n+ %d bytes\n",
+				i+1, a[i].mediaType, len(a[i].data), len(b[i].data))
+		}
+	}
+}
+
+func splitLines(data []byte) []string {
+	s := strings.Replace(string(data), "\r\n", "\n", -1)
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func printLines(w io.Writer, mark string, lines []string) {
+	for _, l := range lines {
+		fmt.Fprintf(w, "%s %s\n", mark, l)
+	}
+}
+
+// lcsLengths returns lengths of the longest common subsequences of a and every prefix of b
+// keeping only two rows of the table in memory
+func lcsLengths(a, b []string) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+func reversed(lines []string) []string {
+	res := make([]string, len(lines))
+	for i, l := range lines {
+		res[len(lines)-1-i] = l
+	}
+	return res
+}
+
+// hirschberg prints a minimal line diff in linear space: b is split where
+// the longest common subsequences of both halves of a with it add up the most
+func hirschberg(w io.Writer, a, b []string) {
+	switch {
+	case len(a) == 0:
+		printLines(w, "+", b)
+		return
+	case len(b) == 0:
+		printLines(w, "-", a)
+		return
+	case len(a) == 1:
+		for j := range b {
+			if b[j] == a[0] {
+				printLines(w, "+", b[:j])
+				printLines(w, "+", b[j+1:])
+				return
+			}
+		}
+		printLines(w, "-", a)
+		printLines(w, "+", b)
+		return
+	}
+	mid := len(a) / 2
+	left := lcsLengths(a[:mid], b)
+	right := lcsLengths(reversed(a[mid:]), reversed(b))
+	split, best := 0, -1
+	for k := 0; k <= len(b); k++ {
+		if n := left[k] + right[len(b)-k]; n > best {
+			split, best = k, n
+		}
+	}
+	hirschberg(w, a[:mid], b[:split])
+	hirschberg(w, a[mid:], b[split:])
+}
+
+// diffLines prints lines removed from a with "-" and lines added in b with "+"
+func diffLines(w io.Writer, a, b []string) {
+	// common head and tail are cheap to skip and are usually most of a part
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	if len(a)*len(b) > maxDiffCells {
+		fmt.Fprintf(w, "- %d lines\n+ %d lines\n", len(a), len(b))
+		return
+	}
+	hirschberg(w, a, b)
+}
+
+// diffMessages compares headers and decoded parts of two messages
+// given as file names, "-" stands for stdin
+func diffMessages(r io.Reader, w io.Writer, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%s: two messages expected", cmdDiff)
+	}
+	a, err := readMessage(args[0], r)
+	if err != nil {
+		return err
+	}
+	b, err := readMessage(args[1], r)
+	if err != nil {
+		return err
+	}
+	diffHeaders(w, a.header, b.header)
+	diffParts(w, a.parts, b.parts)
+	return nil
+}
@@ -2,4 +2,8 @@ module github.com/nchern/cli-tools/pmail
 
 go 1.15
 
-require github.com/DusanKasan/parsemail v1.2.0
+require (
+	github.com/DusanKasan/parsemail v1.2.0
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.13.0
+)
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/textproto"
+
+	"github.com/DusanKasan/parsemail"
+)
+
+// headerCmds lists commands that can be served from the message header alone
+var headerCmds = map[string]bool{
+	cmdCC:      true,
+	cmdTo:      true,
+	cmdID:      true,
+	cmdBCC:     true,
+	cmdDate:    true,
+	cmdFrom:    true,
+	cmdSubject: true,
+}
+
+// parseHeaders reads r only up to the end of the header block and parses
+// the header as a message without a body
+func parseHeaders(r io.Reader) (parsemail.Email, error) {
+	header, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return parsemail.Email{}, err
+	}
+
+	// the body is not read, so parsemail must not try to split it into parts
+	contentType := header.Get("Content-Type")
+	header.Del("Content-Type")
+
+	var buf bytes.Buffer
+	for k, vals := range header {
+		for _, v := range vals {
+			fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+
+	email, err := parsemail.Parse(&buf)
+	if err != nil {
+		return email, err
+	}
+	email.ContentType = contentType
+	return email, nil
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	indexBuild = "build"
+	indexGet   = "get"
+
+	mboxFrom = "From "
+)
+
+var (
+	indexFlags = flag.NewFlagSet(cmdIndex, flag.ExitOnError)
+	indexFile  = indexFlags.String("file", defaultIndexFile(),
+		"index file mapping Message-IDs to message files")
+	indexRaw = indexFlags.Bool("raw", false, "with get print the messages instead of their locations")
+)
+
+func defaultIndexFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "pmail.index"
+	}
+	return filepath.Join(dir, "pmail", "index")
+}
+
+// normalizeID strips angle brackets and spaces so that IDs from
+// References and In-Reply-To headers match the Message-ID ones
+func normalizeID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
+
+// indexEntry is a line of the index file: "<message-id>\t<path>" for a Maildir
+// message or "<message-id>\t<path>\t<offset>" for a message in an mbox file.
+// Lines are kept sorted by the ID, so a lookup binary searches the file.
+type indexEntry struct {
+	id     string
+	path   string
+	offset int64
+	mbox   bool
+}
+
+func (e indexEntry) String() string {
+	if e.mbox {
+		return fmt.Sprintf("%s\t%s\t%d", e.id, e.path, e.offset)
+	}
+	return e.id + "\t" + e.path
+}
+
+// location is how get prints the message: a file or an mbox file with the message offset
+func (e indexEntry) location() string {
+	if e.mbox {
+		return fmt.Sprintf("%s:%d", e.path, e.offset)
+	}
+	return e.path
+}
+
+func parseIndexEntry(line string) (indexEntry, error) {
+	fields := strings.Split(line, "\t")
+	switch len(fields) {
+	case 2:
+		return indexEntry{id: fields[0], path: fields[1]}, nil
+	case 3:
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err == nil {
+			return indexEntry{id: fields[0], path: fields[1], offset: offset, mbox: true}, nil
+		}
+	}
+	return indexEntry{}, fmt.Errorf("malformed index line: %s", line)
+}
+
+func readIndex(name string) ([]indexEntry, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []indexEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e, err := parseIndexEntry(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// writeIndex replaces the index file atomically so that readers never see a partial one
+func writeIndex(name string, entries []indexEntry) error {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), ".index")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		fmt.Fprintln(w, e)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func headerMessageID(r io.Reader) (string, error) {
+	header, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return normalizeID(header.Get("Message-Id")), nil
+}
+
+func readMessageID(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	id, err := headerMessageID(f)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", name, err)
+	}
+	return id, nil
+}
+
+func indexMaildir(dir string) ([]indexEntry, int, error) {
+	files, err := maildirFiles(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := []indexEntry{}
+	for _, name := range files {
+		id, err := readMessageID(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "warn:", err)
+			continue
+		}
+		if id != "" {
+			entries = append(entries, indexEntry{id: id, path: name})
+		}
+	}
+	return entries, len(files), nil
+}
+
+// indexMbox records offsets of "From " lines starting messages of an mbox file
+func indexMbox(name string) ([]indexEntry, int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var (
+		entries = []indexEntry{}
+		total   = 0
+		offset  int64
+		start   int64
+		header  bytes.Buffer
+		// inHeader is set from a "From " line up to the blank line ending the header
+		inHeader bool
+		blank    = true
+	)
+	addMessage := func() {
+		id, err := headerMessageID(&header)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warn: %s:%d: %v\n", name, start, err)
+		} else if id != "" {
+			entries = append(entries, indexEntry{id: id, path: name, offset: start, mbox: true})
+		}
+		header.Reset()
+		inHeader = false
+	}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			switch {
+			case blank && strings.HasPrefix(line, mboxFrom):
+				if inHeader {
+					addMessage()
+				}
+				total++
+				start, inHeader = offset, true
+			case inHeader && strings.TrimRight(line, "\r\n") == "":
+				addMessage()
+			case inHeader:
+				header.WriteString(line)
+			}
+			blank = strings.TrimRight(line, "\r\n") == ""
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if inHeader {
+		addMessage()
+	}
+	if total == 0 {
+		return nil, 0, fmt.Errorf("%s: not an mbox file", name)
+	}
+	return entries, total, nil
+}
+
+// buildIndex (re)indexes messages of a Maildir or an mbox file,
+// entries of other archives are kept
+func buildIndex(w io.Writer, archive string) error {
+	archive, err := filepath.Abs(archive)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Stat(archive)
+	if err != nil {
+		return err
+	}
+	old, err := readIndex(*indexFile)
+	if err != nil {
+		return err
+	}
+
+	var (
+		indexed []indexEntry
+		total   int
+		stale   func(indexEntry) bool
+	)
+	if stat.IsDir() {
+		prefix := archive + string(filepath.Separator)
+		stale = func(e indexEntry) bool { return !e.mbox && strings.HasPrefix(e.path, prefix) }
+		indexed, total, err = indexMaildir(archive)
+	} else {
+		stale = func(e indexEntry) bool { return e.mbox && e.path == archive }
+		indexed, total, err = indexMbox(archive)
+	}
+	if err != nil {
+		return err
+	}
+
+	entries := []indexEntry{}
+	for _, e := range old {
+		if !stale(e) {
+			entries = append(entries, e)
+		}
+	}
+	entries = append(entries, indexed...)
+	if err := writeIndex(*indexFile, entries); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%d of %d messages indexed in %s\n", len(indexed), total, *indexFile)
+	return err
+}
+
+// relocate finds a Maildir message renamed after indexing, e.g. moved
+// from new to cur or with changed flags: its unique name part stays the same
+func relocate(path string) string {
+	if fileExists(path) {
+		return path
+	}
+	base := filepath.Base(path)
+	if i := strings.Index(base, ":"); i >= 0 {
+		base = base[:i]
+	}
+	dir := filepath.Dir(filepath.Dir(path))
+	// messages in new have no flags, the ones in cur have them after a colon
+	if name := filepath.Join(dir, "new", base); fileExists(name) {
+		return name
+	}
+	if name := filepath.Join(dir, "cur", base); fileExists(name) {
+		return name
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "cur", escapeGlob(base)+":*"))
+	if len(matches) > 0 {
+		return matches[0]
+	}
+	return ""
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// escapeGlob quotes glob metacharacters so that a file name matches only itself
+func escapeGlob(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// lineFrom returns the first line of the index starting at or after off, "" at the end
+func lineFrom(f io.ReaderAt, size int64, off int64) (string, error) {
+	if off > 0 {
+		// a line starts at off if the previous byte ends a line
+		off--
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, off, size-off))
+	if off > 0 {
+		if _, err := r.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", err
+		}
+	}
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// searchIndex returns entries with the ID binary searching the sorted index file,
+// so only a few lines of it are read regardless of its size
+func searchIndex(name string, id string) ([]indexEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+
+	var searchErr error
+	// the first offset whose next line has an ID not less than the one looked for
+	off := sort.Search(int(size)+1, func(off int) bool {
+		line, err := lineFrom(f, size, int64(off))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return line == "" || line[:strings.Index(line+"\t", "\t")] >= id
+	})
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(f, 0, size))
+	if off > 0 {
+		// skip the rest of the line lineFrom skipped too
+		if _, err := r.Discard(off - 1); err != nil {
+			return nil, err
+		}
+		if _, err := r.ReadString('\n'); err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+	found := []indexEntry{}
+	for {
+		line, err := r.ReadString('\n')
+		if line = strings.TrimSuffix(line, "\n"); line == "" {
+			break
+		}
+		e, perr := parseIndexEntry(line)
+		if perr != nil {
+			return nil, fmt.Errorf("%s: %v", name, perr)
+		}
+		if e.id != id {
+			break
+		}
+		found = append(found, e)
+		if err != nil {
+			break
+		}
+	}
+	return found, nil
+}
+
+// copyMboxMessage writes the message starting at the "From " line at offset,
+// mboxrd quoting of "From " lines in the body is undone
+func copyMboxMessage(w io.Writer, e indexEntry) error {
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(e.offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	from, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(from, mboxFrom) {
+		return fmt.Errorf("%s: no message at offset %d, rebuild the index", e.path, e.offset)
+	}
+	blank := false
+	for {
+		line, err := r.ReadString('\n')
+		if blank && strings.HasPrefix(line, mboxFrom) {
+			return nil
+		}
+		blank = strings.TrimRight(line, "\r\n") == ""
+		if unquoted := strings.TrimLeft(line, ">"); len(unquoted) < len(line) && strings.HasPrefix(unquoted, mboxFrom) {
+			line = line[1:]
+		}
+		if _, werr := io.WriteString(w, line); werr != nil {
+			return werr
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func copyFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// lookupIndex prints locations, or with -raw contents, of messages with the given Message-ID
+func lookupIndex(w io.Writer, id string) error {
+	id = normalizeID(id)
+	entries, err := searchIndex(*indexFile, id)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, e := range entries {
+		if !e.mbox {
+			path := relocate(e.path)
+			if path == "" {
+				fmt.Fprintf(os.Stderr, "warn: %s: indexed message is gone, rebuild the index\n", e.path)
+				continue
+			}
+			e.path = path
+		}
+		switch {
+		case !*indexRaw:
+			err = printLine(w, e.location())
+		case e.mbox:
+			err = copyMboxMessage(w, e)
+		default:
+			err = copyFile(w, e.path)
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("%s: message not found", id)
+	}
+	return nil
+}
+
+// index maintains a Message-ID to file index over Maildirs and mbox files:
+// "build ARCHIVE" indexes an archive, "get ID" prints where the message is
+func index(r io.Reader, w io.Writer, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%s: expected %s <maildir|mbox> or %s <message-id>", cmdIndex, indexBuild, indexGet)
+	}
+	switch args[0] {
+	case indexBuild:
+		return buildIndex(w, args[1])
+	case indexGet:
+		return lookupIndex(w, args[1])
+	}
+	return fmt.Errorf("%s: unknown subcommand: %s", cmdIndex, args[0])
+}
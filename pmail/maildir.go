@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// progressInterval limits how often progress is redrawn
+const progressInterval = 200 * time.Millisecond
+
+type result struct {
+	out bytes.Buffer
+	err error
+}
+
+// maildirFiles lists messages of a Maildir in the order of their names,
+// which start with the delivery time
+func maildirFiles(dir string) ([]string, error) {
+	files := []string{}
+	for _, sub := range []string{"cur", "new"} {
+		matches, err := filepath.Glob(filepath.Join(dir, sub, "*"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(filepath.Join(dir, "cur")); err != nil {
+			return nil, fmt.Errorf("%s: not a Maildir: %v", dir, err)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return filepath.Base(files[i]) < filepath.Base(files[j])
+	})
+	return files, nil
+}
+
+func handleFile(name string, handle handlerFn) *result {
+	res := &result{}
+	f, err := os.Open(name)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer f.Close()
+	if err := handle(f, &res.out); err != nil {
+		res.err = fmt.Errorf("%s: %v", name, err)
+	}
+	return res
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// processMaildir runs handle over all messages in dir using up to jobs workers.
+// Output keeps the order of messages; broken messages are reported and skipped.
+func processMaildir(dir string, jobs int, handle handlerFn, w io.Writer) error {
+	files, err := maildirFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	results := make([]chan *result, len(files))
+	for i := range results {
+		results[i] = make(chan *result, 1)
+	}
+	// window does not let workers run too far ahead of the output
+	window := make(chan struct{}, 4*jobs)
+	queue := make(chan int)
+	go func() {
+		for i := range files {
+			window <- struct{}{}
+			queue <- i
+		}
+		close(queue)
+	}()
+	for n := 0; n < jobs; n++ {
+		go func() {
+			for i := range queue {
+				results[i] <- handleFile(files[i], handle)
+			}
+		}()
+	}
+
+	showProgress := isTerminal(os.Stderr)
+	lastProgress := time.Time{}
+	failed := 0
+	for i, res := range results {
+		r := <-res
+		<-window
+		if showProgress && time.Since(lastProgress) > progressInterval {
+			fmt.Fprintf(os.Stderr, "\r\033[K%d/%d", i+1, len(files))
+			lastProgress = time.Now()
+		}
+		if r.err != nil {
+			failed++
+			if showProgress {
+				fmt.Fprint(os.Stderr, "\r\033[K")
+			}
+			fmt.Fprintln(os.Stderr, "warn:", r.err)
+			continue
+		}
+		if _, err := w.Write(r.out.Bytes()); err != nil {
+			return err
+		}
+	}
+	if showProgress {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d messages failed", failed, len(files))
+	}
+	return nil
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/mail"
 	"os"
+	"runtime"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/DusanKasan/parsemail"
 )
@@ -16,76 +20,203 @@ const (
 	cmdTo       = "to"
 	cmdID       = "id"
 	cmdBCC      = "bcc"
+	cmdDate     = "date"
 	cmdFrom     = "from"
 	cmdSubject  = "subject"
 	cmdHTMLBody = "html"
 	cmdTextBody = "text"
+	cmdRawPart  = "raw-part"
+	cmdDiff     = "diff"
+	cmdBounce   = "bounce"
+	cmdIndex    = "index"
 )
 
-type cmdFn func(parsemail.Email)
+type cmdFn func(w io.Writer, m parsemail.Email) error
+
+// streamCmdFn works on the raw message instead of the parsed one
+type streamCmdFn func(r io.Reader, w io.Writer, args []string) error
 
 var (
 	commands = map[string]cmdFn{
-		cmdSubject:  func(m parsemail.Email) { fmt.Println(m.Subject) },
-		cmdHTMLBody: func(m parsemail.Email) { fmt.Println(m.HTMLBody) },
-		cmdFrom:     func(m parsemail.Email) { printAddrs(m.From) },
-		cmdTo:       func(m parsemail.Email) { printAddrs(m.To) },
-		cmdCC:       func(m parsemail.Email) { printAddrs(m.Cc) },
-		cmdBCC:      func(m parsemail.Email) { printAddrs(m.Bcc) },
-		cmdTextBody: func(m parsemail.Email) { fmt.Println(m.TextBody) },
-		cmdID:       func(m parsemail.Email) { fmt.Println(m.MessageID) },
+		cmdSubject:  func(w io.Writer, m parsemail.Email) error { return printLine(w, m.Subject) },
+		cmdHTMLBody: func(w io.Writer, m parsemail.Email) error { return writeHTML(w, m.HTMLBody) },
+		cmdFrom:     func(w io.Writer, m parsemail.Email) error { return printAddrs(w, m.From) },
+		cmdTo:       func(w io.Writer, m parsemail.Email) error { return printAddrs(w, m.To) },
+		cmdCC:       func(w io.Writer, m parsemail.Email) error { return printAddrs(w, m.Cc) },
+		cmdBCC:      func(w io.Writer, m parsemail.Email) error { return printAddrs(w, m.Bcc) },
+		cmdTextBody: func(w io.Writer, m parsemail.Email) error { return printLine(w, m.TextBody) },
+		cmdID:       func(w io.Writer, m parsemail.Email) error { return printLine(w, m.MessageID) },
+		cmdDate:     printDate,
+	}
+
+	streamCommands = map[string]streamCmdFn{
+		cmdRawPart: rawPart,
+		cmdDiff:    diffMessages,
+		cmdBounce:  bounce,
+		cmdIndex:   index,
+	}
+
+	htmlFlags = flag.NewFlagSet(cmdHTMLBody, flag.ExitOnError)
+	sanitize  = htmlFlags.Bool("sanitize", false, "strip scripts, remote images and other trackers, dangerous attributes")
+	report    = htmlFlags.Bool("report", false, "with -sanitize print removed remote resources instead of the HTML")
+
+	// cmdFlags holds flags of mail-parts that accept them after the mail-part name
+	cmdFlags = map[string]*flag.FlagSet{
+		cmdHTMLBody: htmlFlags,
+		cmdDiff:     diffFlags,
+		cmdIndex:    indexFlags,
 	}
+
+	headersOnly = flag.Bool("headers-only", false,
+		"stop reading the message after its header if the mail-part does not need the body")
+
+	maildir = flag.String("maildir", "", "extract the mail-part from every message of the Maildir instead of stdin")
+
+	jobs = flag.Int("j", runtime.NumCPU(), "number of messages parsed in parallel with -maildir")
+
+	charset = flag.String("charset", charsetOriginal,
+		"charset of text output: "+charsetUTF8+" converts bodies from their declared charset, "+charsetOriginal+" keeps bytes as is")
 )
 
-func printAddrs(addrs []*mail.Address) {
-	for i, addr := range addrs {
-		if i > 0 {
-			fmt.Print(",")
+func writeHTML(w io.Writer, body string) error {
+	if !*sanitize {
+		_, err := fmt.Fprintln(w, body)
+		return err
+	}
+	safe, trackers, err := sanitizeHTML(body)
+	if err != nil {
+		return err
+	}
+	if *report {
+		for _, url := range trackers {
+			if _, err := fmt.Fprintln(w, url); err != nil {
+				return err
+			}
 		}
-		fmt.Print(addr)
+		return nil
+	}
+	_, err = fmt.Fprintln(w, safe)
+	return err
+}
+
+func printLine(w io.Writer, s string) error {
+	_, err := fmt.Fprintln(w, s)
+	return err
+}
+
+// printDate prints an empty line for a message without a Date header
+func printDate(w io.Writer, m parsemail.Email) error {
+	if m.Date.IsZero() {
+		return printLine(w, "")
+	}
+	return printLine(w, m.Date.Format(time.RFC3339))
+}
+
+func printAddrs(w io.Writer, addrs []*mail.Address) error {
+	strs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		strs[i] = addr.String()
 	}
-	fmt.Println()
+	return printLine(w, strings.Join(strs, ","))
 }
 
 func usage() {
 	fmt.Fprintln(os.Stderr, "Pmail - Parse Mail - is a tool to extract parts of email from a raw SMTP message.")
-	fmt.Fprintln(os.Stderr, "The message is expected on stdin.")
-	fmt.Fprintf(os.Stderr, "\nUsage:\n\n\t%s <mail-part>\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "The message is expected on stdin or messages are read from a Maildir given with -maildir.")
+	fmt.Fprintf(os.Stderr, "\nUsage:\n\n\t%s [flags] <mail-part>\n", os.Args[0])
 	fmt.Fprintln(os.Stderr, "\nParts:")
 
 	sortedCmds := []string{}
 	for cmd := range commands {
 		sortedCmds = append(sortedCmds, cmd)
 	}
+	for cmd := range streamCommands {
+		sortedCmds = append(sortedCmds, cmd)
+	}
 	sort.Strings(sortedCmds)
 	for _, cmd := range sortedCmds {
 		fmt.Fprintf(os.Stderr, "\t%s\n", cmd)
 	}
+	fmt.Fprintf(os.Stderr, "\n%s [-sanitize [-report]] makes the HTML safe to open in a browser.\n", cmdHTMLBody)
+	fmt.Fprintf(os.Stderr, "%s [-all] <file1> <file2> compares two messages, \"-\" reads one of them from stdin.\n", cmdDiff)
+	fmt.Fprintf(os.Stderr, "%s prints failed recipients, status codes and diagnostics of a bounce (RFC3464 report) as JSON.\n", cmdBounce)
+	fmt.Fprintf(os.Stderr, "%s [-file path] [-raw] build <maildir|mbox> | get <message-id> indexes archives by Message-ID and finds messages in them.\n", cmdIndex)
+	fmt.Fprintf(os.Stderr, "%s takes a 1-based index of a MIME part and writes its decoded bytes unchanged.\n", cmdRawPart)
 
+	fmt.Fprintln(os.Stderr, "\nFlags:")
 	flag.PrintDefaults()
 }
 
 func init() {
 	flag.Usage = usage
-
-	flag.Parse()
 }
 
 func main() {
+	flag.Parse()
+
 	cmd := cmdTextBody
-	if len(os.Args) > 1 {
-		cmd = os.Args[1]
+	args := flag.Args()
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+	if fs, found := cmdFlags[cmd]; found {
+		dieIf(fs.Parse(args))
+		args = fs.Args()
 	}
 
-	fn, found := commands[cmd]
-	if !found {
-		dieIf(fmt.Errorf("unknown command: %s", cmd))
+	if *charset != charsetUTF8 && *charset != charsetOriginal {
+		dieIf(fmt.Errorf("unknown charset: %s", *charset))
 	}
 
-	email, err := parsemail.Parse(os.Stdin)
+	handle, err := newHandler(cmd, args)
 	dieIf(err)
 
-	fn(email)
+	if *maildir != "" {
+		if cmd == cmdDiff || cmd == cmdIndex {
+			dieIf(fmt.Errorf("%s: can not be used with -maildir", cmd))
+		}
+		if *jobs <= 0 {
+			dieIf(fmt.Errorf("-j %d should be greater than zero", *jobs))
+		}
+		dieIf(processMaildir(*maildir, *jobs, handle, os.Stdout))
+		return
+	}
+	dieIf(handle(os.Stdin, os.Stdout))
+}
+
+// handlerFn extracts the asked part of a single message
+type handlerFn func(r io.Reader, w io.Writer) error
+
+func newHandler(cmd string, args []string) (handlerFn, error) {
+	streamFn, found := streamCommands[cmd]
+	if !found && *charset == charsetUTF8 {
+		switch cmd {
+		case cmdTextBody:
+			streamFn, found = printBody("text/plain"), true
+		case cmdHTMLBody:
+			streamFn, found = printBody("text/html"), true
+		}
+	}
+	if found {
+		return func(r io.Reader, w io.Writer) error { return streamFn(r, w, args) }, nil
+	}
+
+	fn, found := commands[cmd]
+	if !found {
+		return nil, fmt.Errorf("unknown command: %s", cmd)
+	}
+
+	parse := parsemail.Parse
+	if *headersOnly && headerCmds[cmd] {
+		parse = parseHeaders
+	}
+	return func(r io.Reader, w io.Writer) error {
+		email, err := parse(r)
+		if err != nil {
+			return err
+		}
+		return fn(w, email)
+	}, nil
 }
 
 func dieIf(err error) {
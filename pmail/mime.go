@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	charsetUTF8     = "utf-8"
+	charsetOriginal = "original"
+)
+
+var errStopWalk = errors.New("stop walking parts")
+
+type visitFn func(mediaType string, params map[string]string, body io.Reader) error
+
+// walkParts calls visit for every non-multipart part of the message in the order
+// they appear. Bodies passed to visit are already decoded from their transfer encoding.
+func walkParts(h textproto.MIMEHeader, body io.Reader, visit visitFn) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		// RFC2045: default to plain text if the content type is missing or broken
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkParts(p.Header, p, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return visit(mediaType, params, decodeTransfer(h.Get("Content-Transfer-Encoding"), body))
+}
+
+func walkMessage(r io.Reader, visit visitFn) error {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return err
+	}
+	err = walkParts(textproto.MIMEHeader(msg.Header), msg.Body, visit)
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+func toUTF8(params map[string]string, r io.Reader) (io.Reader, error) {
+	charset := params["charset"]
+	if charset == "" {
+		return r, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unsupported charset", charset)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// rawPart streams decoded bytes of the index-th (1-based) part as is
+func rawPart(r io.Reader, w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%s: exactly one part index expected", cmdRawPart)
+	}
+	idx, err := strconv.Atoi(args[0])
+	if err != nil || idx <= 0 {
+		return fmt.Errorf("%s: part index should be greater than zero", args[0])
+	}
+
+	found := false
+	i := 0
+	err = walkMessage(r, func(mediaType string, params map[string]string, body io.Reader) error {
+		i++
+		if i != idx {
+			return nil
+		}
+		found = true
+		if *charset == charsetUTF8 && strings.HasPrefix(mediaType, "text/") {
+			if body, err = toUTF8(params, body); err != nil {
+				return err
+			}
+		}
+		if _, err := io.Copy(w, body); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no part %d: message has %d parts", idx, i)
+	}
+	return nil
+}
+
+// printBody prints the first part of the given media type converted to utf-8
+func printBody(mediaType string) streamCmdFn {
+	return func(r io.Reader, w io.Writer, args []string) error {
+		return walkMessage(r, func(typ string, params map[string]string, body io.Reader) error {
+			if typ != mediaType {
+				return nil
+			}
+			body, err := toUTF8(params, body)
+			if err != nil {
+				return err
+			}
+			text, err := ioutil.ReadAll(body)
+			if err != nil {
+				return err
+			}
+			content := strings.TrimSuffix(string(text), "\n")
+			if mediaType == "text/html" {
+				err = writeHTML(w, content)
+			} else {
+				_, err = fmt.Fprintln(w, content)
+			}
+			if err != nil {
+				return err
+			}
+			return errStopWalk
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	// unsafeElements are removed together with their content
+	unsafeElements = map[atom.Atom]bool{
+		atom.Script:   true,
+		atom.Iframe:   true,
+		atom.Frame:    true,
+		atom.Frameset: true,
+		atom.Object:   true,
+		atom.Embed:    true,
+		atom.Applet:   true,
+		atom.Form:     true,
+		atom.Base:     true,
+		atom.Link:     true,
+		atom.Meta:     true,
+		// style sheets can load remote content via url() and @import
+		atom.Style: true,
+	}
+
+	// unsafeSVGElements animate attributes of their parents, e.g. turn
+	// a link href into javascript: after the document is sanitized
+	unsafeSVGElements = map[string]bool{
+		"animate":          true,
+		"set":              true,
+		"animatemotion":    true,
+		"animatetransform": true,
+	}
+
+	// cssURLs finds url() arguments and quoted strings, which image-set() takes URLs as
+	cssURLs = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")\s]+)|['"]([^'"]+)['"]`)
+
+	// urlAttrs may point to remote resources loaded when the message is viewed
+	urlAttrs = map[string]bool{
+		"src":        true,
+		"background": true,
+		"poster":     true,
+		"srcset":     true,
+		"lowsrc":     true,
+	}
+
+	// svgResources load the document their href points to
+	svgResources = map[string]bool{
+		"image":   true,
+		"use":     true,
+		"feimage": true,
+	}
+
+	// linkAttrs hold URLs followed on user actions
+	linkAttrs = map[string]bool{
+		"href":       true,
+		"action":     true,
+		"formaction": true,
+		"xlink:href": true,
+	}
+
+	// safeSchemes are allowed in URLs, URLs without a scheme are relative and allowed too
+	safeSchemes = map[string]bool{
+		"http":   true,
+		"https":  true,
+		"mailto": true,
+		"cid":    true,
+	}
+)
+
+// normalizeURL lowercases the URL and drops ASCII whitespace and control
+// characters, which browsers ignore in URLs: "jav\tascript:" is "javascript:"
+func normalizeURL(url string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url))
+}
+
+func isRemote(url string) bool {
+	url = normalizeURL(url)
+	return strings.HasPrefix(url, "http:") ||
+		strings.HasPrefix(url, "https:") ||
+		strings.HasPrefix(url, "//") ||
+		// browsers treat backslashes in URLs as slashes
+		strings.HasPrefix(url, "\\\\") ||
+		strings.HasPrefix(url, "/\\") ||
+		strings.HasPrefix(url, "\\/")
+}
+
+// hasRemote checks every candidate of a srcset list, other attributes hold a single URL
+func hasRemote(key string, val string) bool {
+	if key != "srcset" {
+		return isRemote(val)
+	}
+	for _, candidate := range strings.Split(val, ",") {
+		if isRemote(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlScheme returns the scheme of a normalized URL or "" for relative ones
+func urlScheme(url string) string {
+	i := strings.IndexAny(url, ":/?#")
+	if i < 0 || url[i] != ':' {
+		return ""
+	}
+	return url[:i]
+}
+
+// isSafeLink reports whether the URL has an allowed scheme or is relative
+func isSafeLink(url string) bool {
+	scheme := urlScheme(normalizeURL(url))
+	return scheme == "" || safeSchemes[scheme]
+}
+
+// isSafeResource also allows inline images, remote resources are checked separately
+func isSafeResource(url string) bool {
+	return isSafeLink(url) || strings.HasPrefix(normalizeURL(url), "data:image/")
+}
+
+// unescapeCSS decodes backslash escapes: "u\\72l(" is "url("
+func unescapeCSS(css string) string {
+	var b strings.Builder
+	for i := 0; i < len(css); i++ {
+		if css[i] != '\\' || i+1 == len(css) {
+			b.WriteByte(css[i])
+			continue
+		}
+		j := i + 1
+		for j < len(css) && j < i+7 && strings.IndexByte("0123456789abcdefABCDEF", css[j]) >= 0 {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(css[j])
+			i = j
+			continue
+		}
+		if r, err := strconv.ParseUint(css[i+1:j], 16, 32); err == nil {
+			b.WriteRune(rune(r))
+		}
+		if j < len(css) && strings.IndexByte(" \t\n", css[j]) >= 0 {
+			// a white space ends the hex escape and is a part of it
+			j++
+		}
+		i = j - 1
+	}
+	return b.String()
+}
+
+// isUnsafeStyle reports whether inline CSS may load anything: escapes are
+// refused altogether as they can spell url( in many ways
+func isUnsafeStyle(css string) bool {
+	css = strings.ToLower(css)
+	return strings.Contains(css, "\\") || strings.Contains(css, "url(") || strings.Contains(css, "image-set")
+}
+
+// styleTrackers lists remote URLs referenced by inline CSS
+func styleTrackers(css string) []string {
+	urls := []string{}
+	for _, m := range cssURLs.FindAllStringSubmatch(unescapeCSS(css), -1) {
+		url := m[1] + m[2]
+		if isRemote(url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// sanitizer strips scripts, remote resources and dangerous attributes from HTML
+type sanitizer struct {
+	// trackers lists remote resources found in the document
+	trackers []string
+}
+
+func (s *sanitizer) cleanAttrs(n *html.Node) {
+	// href of SVG elements like <image> loads a resource rather than being a link
+	isResource := func(key string) bool {
+		return urlAttrs[key] || svgResources[strings.ToLower(n.Data)] && (key == "href" || key == "xlink:href")
+	}
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		key := strings.ToLower(a.Key)
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + key
+		}
+		switch {
+		case strings.HasPrefix(key, "on"):
+			continue
+		case key == "ping":
+			// pings are sent to every listed URL when the link is followed
+			for _, url := range strings.Fields(a.Val) {
+				if isRemote(url) {
+					s.trackers = append(s.trackers, url)
+				}
+			}
+			continue
+		case isResource(key) && hasRemote(key, a.Val):
+			s.trackers = append(s.trackers, a.Val)
+			continue
+		case isResource(key) && !isSafeResource(a.Val):
+			continue
+		case linkAttrs[key] && !isSafeLink(a.Val):
+			continue
+		case key == "style" && isUnsafeStyle(a.Val):
+			s.trackers = append(s.trackers, styleTrackers(a.Val)...)
+			continue
+		}
+		attrs = append(attrs, a)
+	}
+	n.Attr = attrs
+}
+
+func (s *sanitizer) clean(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && (unsafeElements[c.DataAtom] || unsafeSVGElements[strings.ToLower(c.Data)]) {
+			if c.DataAtom == atom.Link {
+				for _, a := range c.Attr {
+					if a.Key == "href" && isRemote(a.Val) {
+						s.trackers = append(s.trackers, a.Val)
+					}
+				}
+			}
+			n.RemoveChild(c)
+			c = next
+			continue
+		}
+		if c.Type == html.ElementNode {
+			s.cleanAttrs(c)
+			if c.DataAtom == atom.Img && !hasAttr(c, "src") {
+				// the image was remote: nothing left to show
+				n.RemoveChild(c)
+				c = next
+				continue
+			}
+		}
+		s.clean(c)
+		c = next
+	}
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeHTML returns safe to view HTML and the list of removed remote resources
+func sanitizeHTML(body string) (string, []string, error) {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	s := &sanitizer{}
+	s.clean(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return "", nil, err
+	}
+	return buf.String(), s.trackers, nil
+}
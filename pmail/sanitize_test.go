@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	var tests = []struct {
+		name     string
+		given    string
+		kept     []string
+		removed  []string
+		trackers []string
+	}{
+		{"script",
+			`<p>hi</p><script>alert(1)</script>`,
+			[]string{"<p>hi</p>"}, []string{"alert"}, nil},
+		{"event handler",
+			`<img src="cid:logo" onerror="alert(1)">`,
+			[]string{`src="cid:logo"`}, []string{"onerror"}, nil},
+		{"javascript link",
+			`<a href="javascript:alert(1)">x</a>`,
+			nil, []string{"alert"}, nil},
+		{"javascript link with an entity encoded tab",
+			`<a href="jav&#x09;ascript:alert(1)">x</a>`,
+			nil, []string{"alert"}, nil},
+		{"javascript link with a newline and leading control characters",
+			"<a href=\"\x01 java\nscript:alert(1)\">x</a>",
+			nil, []string{"alert"}, nil},
+		{"vbscript link",
+			`<a href="VBScript:msgbox(1)">x</a>`,
+			nil, []string{"msgbox"}, nil},
+		{"data link",
+			`<a href="data:text/html,<script>alert(1)</script>">x</a>`,
+			nil, []string{"data:"}, nil},
+		{"safe links",
+			`<a href="https://example.com/">a</a><a href="mailto:a@example.com">b</a><a href="/rel">c</a>`,
+			[]string{`href="https://example.com/"`, `href="mailto:a@example.com"`, `href="/rel"`}, nil, nil},
+		{"remote image",
+			`<img src="http://t/q.gif">`,
+			nil, []string{"<img"}, []string{"http://t/q.gif"}},
+		{"remote image with a tab in the scheme",
+			"<img src=\"ht\ttp://t/q.gif\">",
+			nil, []string{"<img"}, []string{"ht\ttp://t/q.gif"}},
+		{"remote image in srcset",
+			`<img src="cid:a" srcset="cid:b 1x, http://t/q.gif 2x">`,
+			[]string{`src="cid:a"`}, []string{"srcset"}, []string{"cid:b 1x, http://t/q.gif 2x"}},
+		{"svg image",
+			`<svg><image href="http://t/q.gif"></image></svg>`,
+			nil, []string{"http://t/q.gif"}, []string{"http://t/q.gif"}},
+		{"svg image with xlink",
+			`<svg><image xlink:href="http://t/q.gif"></image></svg>`,
+			nil, []string{"http://t/q.gif"}, []string{"http://t/q.gif"}},
+		{"svg use",
+			`<svg><use href="https://t/s.svg#a"></use></svg>`,
+			nil, []string{"https://t/s.svg"}, []string{"https://t/s.svg#a"}},
+		{"svg feImage",
+			`<svg><filter><feImage href="//t/q.gif"></feImage></filter></svg>`,
+			nil, []string{"//t/q.gif"}, []string{"//t/q.gif"}},
+		{"svg javascript link",
+			`<svg><a xlink:href="javascript:alert(1)"><text>x</text></a></svg>`,
+			nil, []string{"alert"}, nil},
+		{"link ping",
+			`<a href="https://example.com/" ping="http://t/ping http://t/ping2">x</a>`,
+			[]string{`href="https://example.com/"`}, []string{"ping"}, []string{"http://t/ping", "http://t/ping2"}},
+		{"style with url",
+			`<p style="background: url(http://t/q.gif)">x</p>`,
+			nil, []string{"url("}, []string{"http://t/q.gif"}},
+		{"style with an escaped url",
+			`<p style="background:u\72l(http://t/q.gif)">x</p>`,
+			nil, []string{"style"}, []string{"http://t/q.gif"}},
+		{"style with image-set",
+			`<p style="background-image: image-set('http://t/q.gif' 1x, 'cid:a' 2x)">x</p>`,
+			nil, []string{"style"}, []string{"http://t/q.gif"}},
+		{"safe style",
+			`<p style="color: red">x</p>`,
+			[]string{`style="color: red"`}, nil, nil},
+		{"svg set",
+			`<svg><set attributeName="href" to="javascript:alert(1)"/></svg>`,
+			nil, []string{"alert", "<set"}, nil},
+		{"svg animate",
+			`<svg><a><animate attributeName="href" values="javascript:alert(1)"/><text>x</text></a></svg>`,
+			nil, []string{"alert", "<animate"}, nil},
+		{"svg animateMotion and animateTransform",
+			`<svg><animateMotion path="M0,0"/><animateTransform attributeName="transform"/></svg>`,
+			nil, []string{"animateMotion", "animateTransform"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, trackers, err := sanitizeHTML(tt.given)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.kept {
+				if !strings.Contains(actual, s) {
+					t.Errorf("%q is not kept in %s", s, actual)
+				}
+			}
+			for _, s := range tt.removed {
+				if strings.Contains(actual, s) {
+					t.Errorf("%q is not removed from %s", s, actual)
+				}
+			}
+			if strings.Join(trackers, " ") != strings.Join(tt.trackers, " ") {
+				t.Errorf("trackers: expected %q, got %q", tt.trackers, trackers)
+			}
+		})
+	}
+}